@@ -0,0 +1,214 @@
+// mock-oidc es un IdP mínimo para probar el SSO en local. NO usar en producción.
+//
+// Aprueba cualquier login sin pedir credenciales. La identidad se elige con parámetros extra en la
+// authorization URL: login_hint (email), name y groups (separados por coma). Ejemplo:
+//
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER=http://localhost:9000
+//	OIDC_MOCK_CLIENT_ID=handsoft
+//	OIDC_MOCK_REDIRECT_URL=http://localhost:4200/auth/callback
+//	OIDC_MOCK_GROUP_ROLES=admins=super_admin
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const kid = "mock-1"
+
+type authCode struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	email       string
+	name        string
+	groups      []string
+	expiresAt   time.Time
+}
+
+type server struct {
+	issuer string
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authCode
+}
+
+func main() {
+	port := os.Getenv("MOCK_OIDC_PORT")
+	if port == "" {
+		port = "9000"
+	}
+	issuer := os.Getenv("MOCK_OIDC_ISSUER")
+	if issuer == "" {
+		issuer = "http://localhost:" + port
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := &server{issuer: issuer, key: key, codes: map[string]authCode{}}
+
+	r := gin.Default()
+	r.GET("/.well-known/openid-configuration", s.discovery)
+	r.GET("/authorize", s.authorize)
+	r.POST("/token", s.token)
+	r.GET("/jwks", s.jwks)
+
+	log.Printf("mock-oidc escuchando en %s (issuer %s)", port, issuer)
+	if err := r.Run(":" + port); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (s *server) discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize aprueba de inmediato y redirige con el code.
+func (s *server) authorize(c *gin.Context) {
+	redirectURI := c.Query("redirect_uri")
+	if c.Query("response_type") != "code" || redirectURI == "" || c.Query("client_id") == "" {
+		c.String(http.StatusBadRequest, "invalid_request")
+		return
+	}
+	if c.Query("code_challenge") == "" || c.Query("code_challenge_method") != "S256" {
+		c.String(http.StatusBadRequest, "PKCE S256 requerido")
+		return
+	}
+
+	email := c.DefaultQuery("login_hint", "jdoe@example.com")
+	var groups []string
+	for _, g := range strings.Split(c.Query("groups"), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = authCode{
+		clientID:    c.Query("client_id"),
+		redirectURI: redirectURI,
+		challenge:   c.Query("code_challenge"),
+		nonce:       c.Query("nonce"),
+		email:       strings.ToLower(email),
+		name:        c.DefaultQuery("name", "Mock User"),
+		groups:      groups,
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		c.String(http.StatusBadRequest, "redirect_uri inválida")
+		return
+	}
+	q := u.Query()
+	q.Set("code", code)
+	q.Set("state", c.Query("state"))
+	u.RawQuery = q.Encode()
+
+	c.Redirect(http.StatusFound, u.String())
+}
+
+func (s *server) token(c *gin.Context) {
+	code := c.PostForm("code")
+
+	s.mu.Lock()
+	ac, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	if c.PostForm("grant_type") != "authorization_code" || !ok || time.Now().After(ac.expiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant"})
+		return
+	}
+	if c.PostForm("client_id") != ac.clientID || c.PostForm("redirect_uri") != ac.redirectURI {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(c.PostForm("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != ac.challenge {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "code_verifier inválido"})
+		return
+	}
+
+	now := time.Now()
+	sub := sha256.Sum256([]byte(ac.email))
+	claims := jwt.MapClaims{
+		"iss":                s.issuer,
+		"sub":                base64.RawURLEncoding.EncodeToString(sub[:12]),
+		"aud":                ac.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              ac.nonce,
+		"email":              ac.email,
+		"email_verified":     true,
+		"name":               ac.name,
+		"preferred_username": strings.Split(ac.email, "@")[0],
+		"groups":             ac.groups,
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = kid
+	idToken, err := t.SignedString(s.key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *server) jwks(c *gin.Context) {
+	pub := s.key.PublicKey
+	c.JSON(http.StatusOK, gin.H{"keys": []gin.H{{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"kid": kid,
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
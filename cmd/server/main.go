@@ -1,14 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"handsoft/internal/auth"
 	"handsoft/internal/db"
+	"handsoft/internal/http/handlers"
 	"handsoft/internal/http/middleware"
 	"handsoft/internal/http/routes"
+	"handsoft/internal/mail"
 	"handsoft/internal/models"
+	"handsoft/internal/oidc"
+	"handsoft/internal/permissions"
+	"handsoft/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -29,6 +38,18 @@ func main() {
 		jwtSecret = "dev-secret-change-me"
 	}
 
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:4200"
+	}
+
+	// Política para cuentas sin email verificado: allow | grace | block
+	unverifiedPolicy := os.Getenv("UNVERIFIED_LOGIN_POLICY")
+	if unverifiedPolicy == "" {
+		unverifiedPolicy = handlers.UnverifiedLoginAllow
+	}
+	graceHours := envInt("UNVERIFIED_GRACE_HOURS", 72)
+
 	port := os.Getenv("APP_PORT")
 	if port == "" {
 		port = "8080"
@@ -42,20 +63,218 @@ func main() {
 	if err := gormDB.AutoMigrate(models.Models()...); err != nil {
 		log.Fatal(err)
 	}
+	if err := db.BackfillUserAddresses(gormDB); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.BackfillEmailVerified(gormDB); err != nil {
+		log.Fatal(err)
+	}
+
+	// Catálogo de permisos declarado en código (internal/permissions) => tabla permissions
+	if err := permissions.Sync(gormDB); err != nil {
+		log.Fatal(err)
+	}
 
-	r := gin.Default()
+	// Firma JWT: HS256 (default, con JWT_SECRET) o asimétrica con rotación (JWT_ALG=RS256|EdDSA)
+	var keys *auth.KeySet
+	if alg := os.Getenv("JWT_ALG"); alg != "" && alg != "HS256" {
+		rotateEvery := time.Duration(envInt("JWT_ROTATE_DAYS", 30)) * 24 * time.Hour
+		keys, err = auth.NewKeySet(gormDB, alg, rotateEvery, 24*time.Hour)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go keys.Run(context.Background(), auth.KeyReloadEvery)
+	}
+
+	// Hash de contraseñas: PASSWORD_HASH=bcrypt|argon2id. Subir costos regenera los hashes en el próximo login.
+	hasher := auth.PasswordHasher{
+		Algorithm:  os.Getenv("PASSWORD_HASH"),
+		BcryptCost: envInt("BCRYPT_COST", 12),
+		Argon2: auth.Argon2Params{
+			Memory:      uint32(envInt("ARGON2_MEMORY_KB", 64*1024)),
+			Iterations:  uint32(envInt("ARGON2_ITERATIONS", 3)),
+			Parallelism: uint8(envInt("ARGON2_PARALLELISM", 2)),
+		},
+	}
+	if _, err := hasher.Hash("startup-check"); err != nil {
+		log.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(middleware.RequestLogger(), gin.Recovery())
 
 	// ✅ CORS GLOBAL (antes de routes.Register)
 	r.Use(middleware.CORS())
 
+	// Archivos subidos (fotos de perfil); con almacenamiento local se sirven desde la misma API
+	store := newStorage()
+	if local, ok := store.(*storage.LocalStore); ok && strings.HasPrefix(local.BaseURL, "/") {
+		r.Static(local.BaseURL, local.Dir)
+	}
+
 	routes.Register(r, routes.Deps{
 		DB:        gormDB,
 		JWTSecret: jwtSecret,
 		Issuer:    "handsoft-api",
-		AccessTTL: 24 * time.Hour,
+		AccessTTL: 15 * time.Minute,
+		Keys:      keys,
+
+		RefreshTTL: 30 * 24 * time.Hour,
+
+		Revocations: auth.NewRevocationStore(gormDB, 30*time.Second),
+
+		Mailer: newMailer(),
+		AppURL: appURL,
+
+		// PUBLIC_REGISTRATION=false deja el alta solo por invitación
+		InvitationTTL:             time.Duration(envInt("INVITATION_TTL_HOURS", 7*24)) * time.Hour,
+		DisablePublicRegistration: os.Getenv("PUBLIC_REGISTRATION") == "false",
+
+		EmailVerification: handlers.EmailVerificationPolicy{
+			Mode:        unverifiedPolicy,
+			GracePeriod: time.Duration(graceHours) * time.Hour,
+			TokenTTL:    48 * time.Hour,
+		},
+
+		LoginProtection: handlers.LoginProtection{
+			MaxFailures:     5,
+			LockoutDuration: 15 * time.Minute,
+			BaseDelay:       time.Second,
+			MaxDelay:        30 * time.Second,
+			IPMaxFailures:   50,
+			IPWindow:        15 * time.Minute,
+		},
+
+		PasswordPolicy: auth.PasswordPolicy{
+			MinLength:          envInt("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:          72,
+			RequireUpper:       true,
+			RequireLower:       true,
+			RequireDigit:       true,
+			RequireSymbol:      os.Getenv("PASSWORD_REQUIRE_SYMBOL") == "true",
+			ForbidPersonalInfo: true,
+			ForbidCommon:       true,
+			HistorySize:        envInt("PASSWORD_HISTORY_SIZE", 5),
+		},
+
+		PasswordHasher: hasher,
+
+		OIDC: newOIDCProviders(),
+
+		Storage: store,
 	})
 
 	if err := r.Run(":" + port); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// newMailer elige el envío de correos según MAIL_DRIVER:
+// "smtp" (SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD) o "outbox" (archivos .eml en MAIL_OUTBOX_DIR).
+func newMailer() mail.Sender {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@handsoft.local"
+	}
+
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		return &mail.SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     envInt("SMTP_PORT", 587),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	default:
+		dir := os.Getenv("MAIL_OUTBOX_DIR")
+		if dir == "" {
+			dir = "tmp/outbox"
+		}
+		return &mail.OutboxSender{Dir: dir, From: from}
+	}
+}
+
+// newStorage elige dónde se guardan los archivos subidos según STORAGE_DRIVER:
+// "s3" (S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY, S3_PATH_STYLE, S3_PUBLIC_URL)
+// o "local" (STORAGE_LOCAL_DIR, servido en STORAGE_LOCAL_URL).
+func newStorage() storage.Store {
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "s3":
+		return &storage.S3Store{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		}
+	default:
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "tmp/media"
+		}
+		baseURL := os.Getenv("STORAGE_LOCAL_URL")
+		if baseURL == "" {
+			baseURL = "/media"
+		}
+		return &storage.LocalStore{Dir: dir, BaseURL: baseURL}
+	}
+}
+
+// newOIDCProviders arma los proveedores de SSO listados en OIDC_PROVIDERS (ej: "azure,google").
+// Cada uno se configura con OIDC_<NOMBRE>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL,
+// _SCOPES, _GROUPS_CLAIM, _DEFAULT_ROLE, _GROUP_ROLES ("grupo=rol,grupo2=rol2") y
+// _TRUST_EMAIL_LINKING (true = vincular por email sin confirmación del usuario).
+func newOIDCProviders() map[string]*oidc.Provider {
+	providers := map[string]*oidc.Provider{}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		cfg := oidc.Config{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+			GroupsClaim:  os.Getenv(prefix + "GROUPS_CLAIM"),
+			DefaultRole:  os.Getenv(prefix + "DEFAULT_ROLE"),
+			GroupRoles:   map[string]string{},
+
+			TrustEmailLinking: os.Getenv(prefix+"TRUST_EMAIL_LINKING") == "true",
+		}
+		if cfg.DefaultRole == "" {
+			cfg.DefaultRole = "user"
+		}
+		if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			log.Fatalf("OIDC %s: faltan %sISSUER, %sCLIENT_ID o %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+
+		for _, pair := range strings.Split(os.Getenv(prefix+"GROUP_ROLES"), ",") {
+			group, role, ok := strings.Cut(pair, "=")
+			if ok && strings.TrimSpace(group) != "" && strings.TrimSpace(role) != "" {
+				cfg.GroupRoles[strings.TrimSpace(group)] = strings.TrimSpace(role)
+			}
+		}
+
+		providers[name] = oidc.NewProvider(cfg)
+	}
+
+	return providers
+}
+
+// envInt lee un entero positivo de una variable de entorno, con valor por defecto.
+func envInt(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
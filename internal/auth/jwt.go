@@ -11,37 +11,210 @@ type JWTConfig struct {
 	Secret    string
 	AccessTTL time.Duration
 	Issuer    string
+
+	// Si no es nil se firma con la llave asimétrica vigente (RS256/EdDSA, header kid)
+	// y Secret deja de aceptarse. Si es nil se usa HS256 con Secret.
+	Keys *KeySet
 }
 
 type Claims struct {
 	UserID uint     `json:"uid"`
 	Roles  []string `json:"roles"`
+
+	// Tipo de sujeto: "user" o "service". Tokens antiguos sin el claim son de usuario.
+	SubType string `json:"sub_type,omitempty"`
+	// Solo en tokens de service account (UserID queda en 0)
+	ServiceAccountID uint `json:"sa_id,omitempty"`
+
+	// Sesión (familia de refresh tokens) a la que pertenece el access token
+	SessionID uint `json:"sid,omitempty"`
+
+	// Impersonación: el token actúa como UserID pero lo emitió ActorID (super admin).
+	// Impersonated marca el token explícitamente para otros servicios.
+	ActorID      uint `json:"actor_uid,omitempty"`
+	Impersonated bool `json:"imp,omitempty"`
+
+	// Vacío para access tokens. Tokens de un paso intermedio (ej: desafío MFA)
+	// llevan un propósito y no sirven como access token.
+	Purpose string `json:"purpose,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
-func SignAccessToken(cfg JWTConfig, userID uint, roles []string) (string, error) {
+// Tipos de sujeto (claim sub_type)
+const (
+	SubTypeUser    = "user"
+	SubTypeService = "service"
+)
+
+// IsService indica si el token es de una service account (client credentials).
+func (c *Claims) IsService() bool {
+	return c.SubType == SubTypeService
+}
+
+// Propósitos de tokens intermedios
+const (
+	PurposeMFALogin  = "mfa_login"  // password OK, falta el segundo factor
+	PurposeMFAEnroll = "mfa_enroll" // password OK, el rol exige MFA y el usuario no lo tiene
+)
+
+// SignAccessToken firma el access token de una sesión. issuedAt viene de RevocationStore.UserIssueTime
+// (posterior a cualquier corte de revocación del usuario).
+func SignAccessToken(cfg JWTConfig, userID uint, roles []string, sessionID uint, issuedAt time.Time) (string, error) {
+	jti, err := NewRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	claims := Claims{
+		UserID:    userID,
+		Roles:     roles,
+		SubType:   SubTypeUser,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti, // permite revocar el token puntual (logout)
+			Issuer:    cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(cfg.AccessTTL)),
+		},
+	}
+
+	return signClaims(cfg, claims)
+}
+
+// SignImpersonationToken firma un access token de corta duración para que actorID actúe como userID.
+// No tiene sesión ni refresh token asociado.
+func SignImpersonationToken(cfg JWTConfig, actorID, userID uint, roles []string, ttl time.Duration) (string, error) {
+	jti, err := NewRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := Claims{
+		UserID:       userID,
+		Roles:        roles,
+		SubType:      SubTypeUser,
+		ActorID:      actorID,
+		Impersonated: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return signClaims(cfg, claims)
+}
+
+// SignServiceToken firma un access token para una service account (client credentials).
+// No tiene sesión ni refresh token: el cliente pide uno nuevo cuando expira.
+// issuedAt viene de RevocationStore.ServiceIssueTime.
+func SignServiceToken(cfg JWTConfig, accountID uint, clientID string, roles []string, issuedAt time.Time) (string, error) {
+	jti, err := NewRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	claims := Claims{
+		Roles:            roles,
+		SubType:          SubTypeService,
+		ServiceAccountID: accountID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   clientID,
+			Issuer:    cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(cfg.AccessTTL)),
+		},
+	}
+
+	return signClaims(cfg, claims)
+}
+
+// SignPurposeToken firma un token de corta duración para un paso intermedio (ej: desafío MFA).
+func SignPurposeToken(cfg JWTConfig, userID uint, purpose string, ttl time.Duration) (string, error) {
+	jti, err := NewRandomID()
+	if err != nil {
+		return "", err
+	}
+
 	now := time.Now()
 	claims := Claims{
-		UserID: userID,
-		Roles:  roles,
+		UserID:  userID,
+		Purpose: purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			Issuer:    cfg.Issuer,
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(cfg.AccessTTL)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
 	}
 
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return t.SignedString([]byte(cfg.Secret))
+	return signClaims(cfg, claims)
+}
+
+func signClaims(cfg JWTConfig, claims Claims) (string, error) {
+	if cfg.Keys == nil {
+		t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return t.SignedString([]byte(cfg.Secret))
+	}
+
+	key, err := cfg.Keys.Current()
+	if err != nil {
+		return "", err
+	}
+
+	t := jwt.NewWithClaims(key.method(), claims)
+	t.Header["kid"] = key.Kid
+	return t.SignedString(key.Private)
 }
 
+// VerifyPurposeToken valida un token intermedio con el propósito esperado.
+func VerifyPurposeToken(cfg JWTConfig, tokenStr, purpose string) (*Claims, error) {
+	claims, err := parseToken(cfg, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("token inválido")
+	}
+	return claims, nil
+}
+
+// VerifyAccessToken valida un access token (rechaza tokens intermedios).
 func VerifyAccessToken(cfg JWTConfig, tokenStr string) (*Claims, error) {
+	claims, err := parseToken(cfg, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("token inválido")
+	}
+	return claims, nil
+}
+
+func parseToken(cfg JWTConfig, tokenStr string) (*Claims, error) {
 	keyFn := func(t *jwt.Token) (any, error) {
-		// Asegurar algoritmo
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		if cfg.Keys == nil {
+			// Asegurar algoritmo
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("método de firma inválido")
+			}
+			return []byte(cfg.Secret), nil
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		key, ok := cfg.Keys.Lookup(kid)
+		if !ok {
+			return nil, errors.New("kid desconocido")
+		}
+		// Asegurar que el algoritmo del token sea el de la llave
+		if t.Method.Alg() != key.Alg {
 			return nil, errors.New("método de firma inválido")
 		}
-		return []byte(cfg.Secret), nil
+		return key.Private.Public(), nil
 	}
 
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, keyFn, jwt.WithIssuer(cfg.Issuer))
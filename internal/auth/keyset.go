@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"handsoft/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Algoritmos asimétricos soportados
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey es una llave cargada en memoria.
+type SigningKey struct {
+	Kid         string
+	Alg         string
+	Private     crypto.Signer
+	ActivatesAt time.Time
+	ExpiresAt   time.Time
+}
+
+func (k SigningKey) method() jwt.SigningMethod {
+	if k.Alg == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// Tiempos de propagación de llaves entre instancias y consumidores del JWKS
+const (
+	JWKSMaxAge     = 5 * time.Minute  // Cache-Control del JWKS
+	KeyReloadEvery = 10 * time.Minute // cada cuánto Run recarga las llaves de la DB
+
+	// Recarga por kid desconocido: como mucho una cada minMissReload (un kid inventado no martilla la DB)
+	minMissReload = 30 * time.Second
+
+	// Lock de Postgres (pg_advisory_xact_lock) para que una sola instancia rote a la vez
+	keyRotationLockID = 0x6a776b73 // "jwks"
+)
+
+// KeySet maneja las llaves de firma asimétricas persistidas en DB, con rotación programada:
+// cada RotateEvery se genera una llave nueva para firmar, y las anteriores siguen sirviendo
+// para verificar (y se publican en el JWKS) durante VerifyFor.
+//
+// La llave nueva se publica PublishAhead antes de empezar a firmar, para que las demás
+// instancias (que recargan cada KeyReloadEvery) y los consumidores del JWKS (que lo cachean
+// JWKSMaxAge) ya la conozcan cuando llegue el primer token firmado con ella.
+type KeySet struct {
+	DB           *gorm.DB
+	Alg          string
+	RotateEvery  time.Duration
+	VerifyFor    time.Duration // debe ser mayor que la vida del access token
+	PublishAhead time.Duration
+
+	mu   sync.RWMutex
+	keys []SigningKey // ordenadas por ActivatesAt desc
+
+	missMu         sync.Mutex
+	lastMissReload time.Time
+}
+
+// NewKeySet carga las llaves vigentes y genera una si no hay ninguna.
+func NewKeySet(db *gorm.DB, alg string, rotateEvery, verifyFor time.Duration) (*KeySet, error) {
+	if alg != AlgRS256 && alg != AlgEdDSA {
+		return nil, fmt.Errorf("algoritmo JWT no soportado: %s", alg)
+	}
+
+	ks := &KeySet{
+		DB:           db,
+		Alg:          alg,
+		RotateEvery:  rotateEvery,
+		VerifyFor:    verifyFor,
+		PublishAhead: JWKSMaxAge + KeyReloadEvery + time.Minute,
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	if err := ks.rotateIfDue(time.Now()); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Current devuelve la llave con la que se firma ahora.
+func (ks *KeySet) Current() (SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	for _, k := range ks.keys {
+		if k.Alg == ks.Alg && !k.ActivatesAt.After(now) && now.Before(k.ExpiresAt) {
+			return k, nil
+		}
+	}
+	return SigningKey{}, errors.New("no hay llave de firma vigente")
+}
+
+// Lookup busca una llave (vigente o en período de verificación) por kid. Si no la conoce,
+// recarga desde la DB (con límite de frecuencia): puede ser una llave recién creada por otra instancia.
+func (ks *KeySet) Lookup(kid string) (SigningKey, bool) {
+	if k, ok := ks.find(kid); ok {
+		return k, true
+	}
+	if !ks.reloadOnMiss() {
+		return SigningKey{}, false
+	}
+	return ks.find(kid)
+}
+
+func (ks *KeySet) find(kid string) (SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	for _, k := range ks.keys {
+		if k.Kid == kid && now.Before(k.ExpiresAt) {
+			return k, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// reloadOnMiss recarga las llaves si pasó minMissReload desde la última recarga por kid desconocido.
+func (ks *KeySet) reloadOnMiss() bool {
+	ks.missMu.Lock()
+	defer ks.missMu.Unlock()
+
+	if time.Since(ks.lastMissReload) < minMissReload {
+		return false
+	}
+	ks.lastMissReload = time.Now()
+
+	if err := ks.Reload(); err != nil {
+		log.Printf("jwt keys: no se pudieron recargar por kid desconocido: %v", err)
+		return false
+	}
+	return true
+}
+
+// Reload vuelve a leer las llaves no expiradas desde la DB (incluye las creadas por otras instancias).
+func (ks *KeySet) Reload() error {
+	var rows []models.SigningKey
+	if err := ks.DB.Where("expires_at > ?", time.Now()).
+		Order("activates_at desc").
+		Find(&rows).Error; err != nil {
+		return err
+	}
+
+	keys := make([]SigningKey, 0, len(rows))
+	for _, r := range rows {
+		priv, err := parsePrivateKeyPEM(r.PrivateKeyPEM)
+		if err != nil {
+			return fmt.Errorf("llave %s: %w", r.Kid, err)
+		}
+		keys = append(keys, SigningKey{
+			Kid:         r.Kid,
+			Alg:         r.Alg,
+			Private:     priv,
+			ActivatesAt: r.ActivatesAt,
+			ExpiresAt:   r.ExpiresAt,
+		})
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+// Rotate genera y persiste una llave nueva, que empieza a firmar en PublishAhead (de inmediato
+// si no hay ninguna vigente). La llave anterior firma hasta entonces y sigue verificando hasta su ExpiresAt.
+func (ks *KeySet) Rotate() error {
+	return ks.rotate(time.Now(), true)
+}
+
+// rotate corre con un lock de Postgres: la decisión se toma con las llaves de la DB, no con las
+// de memoria, para que dos instancias no roten a la vez. force = rotar aunque no toque todavía.
+func (ks *KeySet) rotate(now time.Time, force bool) error {
+	err := ks.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", keyRotationLockID).Error; err != nil {
+			return err
+		}
+
+		var latest models.SigningKey
+		res := tx.Where("alg = ? AND expires_at > ?", ks.Alg, now).
+			Order("activates_at desc").
+			Limit(1).
+			Find(&latest)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		activatesAt := now.Add(ks.PublishAhead)
+		switch {
+		case res.RowsAffected == 0:
+			// Sin llave vigente no hay con qué firmar: la nueva parte de inmediato
+			activatesAt = now
+		case latest.ActivatesAt.After(now):
+			// Ya hay una llave publicada esperando su turno (de esta u otra instancia)
+			return nil
+		case !force && now.Sub(latest.ActivatesAt) < ks.RotateEvery-ks.PublishAhead:
+			return nil
+		}
+
+		row, err := ks.newKeyRow(activatesAt)
+		if err != nil {
+			return err
+		}
+		return tx.Create(row).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return ks.Reload()
+}
+
+func (ks *KeySet) newKeyRow(activatesAt time.Time) (*models.SigningKey, error) {
+	priv, err := generateKey(ks.Alg)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := NewRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SigningKey{
+		Kid:           kid,
+		Alg:           ks.Alg,
+		PrivateKeyPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		ActivatesAt:   activatesAt,
+		// Firma durante RotateEvery y luego verifica durante VerifyFor
+		ExpiresAt: activatesAt.Add(ks.RotateEvery + ks.VerifyFor),
+	}, nil
+}
+
+// Run revisa periódicamente si toca rotar y recarga llaves de otras instancias.
+func (ks *KeySet) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if err := ks.Reload(); err != nil {
+				log.Printf("jwt keys: no se pudieron recargar: %v", err)
+				continue
+			}
+			if err := ks.rotateIfDue(now); err != nil {
+				log.Printf("jwt keys: no se pudo rotar: %v", err)
+			}
+		}
+	}
+}
+
+// rotateIfDue publica la llave siguiente cuando a la actual le queda PublishAhead de firma
+// (así la nueva se activa justo al cumplir RotateEvery).
+func (ks *KeySet) rotateIfDue(now time.Time) error {
+	ks.mu.RLock()
+	pending := false
+	for _, k := range ks.keys {
+		if k.Alg == ks.Alg && k.ActivatesAt.After(now) {
+			pending = true
+		}
+	}
+	ks.mu.RUnlock()
+
+	cur, err := ks.Current()
+	if pending || (err == nil && now.Sub(cur.ActivatesAt) < ks.RotateEvery-ks.PublishAhead) {
+		return nil
+	}
+	return ks.rotate(now, false)
+}
+
+// JWK es una llave pública en formato JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS devuelve las llaves públicas vigentes y en período de verificación.
+func (ks *KeySet) JWKS() []JWK {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	out := make([]JWK, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		if !now.Before(k.ExpiresAt) {
+			continue
+		}
+		jwk := JWK{Use: "sig", Alg: k.Alg, Kid: k.Kid}
+		switch pub := k.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		out = append(out, jwk)
+	}
+	return out
+}
+
+func generateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case AlgRS256:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case AlgEdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	}
+	return nil, fmt.Errorf("algoritmo JWT no soportado: %s", alg)
+}
+
+func parsePrivateKeyPEM(s string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("pem inválido")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("tipo de llave no soportado")
+	}
+	return signer, nil
+}
@@ -1,15 +1,202 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 
-func HashPassword(plain string) (string, error) {
-	b, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algoritmos de hash de contraseñas
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// UnusablePasswordHash no corresponde a ninguna contraseña (Verify siempre falla).
+// Se usa para cuentas creadas sin contraseña, que solo entran con un link de reset.
+const UnusablePasswordHash = "!"
+
+// Argon2Params son los parámetros de argon2id (Memory en KiB).
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Valores recomendados por RFC 9106 para entornos con poca memoria
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// PasswordHasher genera hashes con el algoritmo configurado y verifica hashes de cualquier
+// algoritmo soportado. Cada hash lleva su algoritmo y parámetros codificados:
+//   - bcrypt:   $2a$<cost>$...
+//   - argon2id: $argon2id$v=19$m=<KiB>,t=<iter>,p=<par>$<salt>$<hash>   (formato PHC)
+//
+// El valor cero usa bcrypt con bcrypt.DefaultCost.
+type PasswordHasher struct {
+	Algorithm  string // HashBcrypt (default) o HashArgon2id
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+func (h PasswordHasher) algorithm() string {
+	if h.Algorithm == "" {
+		return HashBcrypt
+	}
+	return h.Algorithm
+}
+
+func (h PasswordHasher) bcryptCost() int {
+	if h.BcryptCost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return h.BcryptCost
+}
+
+func (h PasswordHasher) argon2Params() Argon2Params {
+	p := h.Argon2
+	d := DefaultArgon2Params
+	if p.Memory == 0 {
+		p.Memory = d.Memory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = d.Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = d.Parallelism
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = d.SaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = d.KeyLength
+	}
+	return p
+}
+
+// Hash genera el hash codificado de la contraseña.
+func (h PasswordHasher) Hash(plain string) (string, error) {
+	switch h.algorithm() {
+	case HashBcrypt:
+		b, err := bcrypt.GenerateFromPassword([]byte(plain), h.bcryptCost())
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+
+	case HashArgon2id:
+		p := h.argon2Params()
+		salt := make([]byte, p.SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(plain), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+
+	default:
+		return "", fmt.Errorf("algoritmo de hash no soportado: %s", h.Algorithm)
+	}
+}
+
+// Verify compara la contraseña con el hash. needsRehash indica que el hash es válido pero usa
+// otro algoritmo o parámetros más débiles que los configurados (se debe regenerar en el login).
+func (h PasswordHasher) Verify(encoded, plain string) (ok, needsRehash bool) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false
+		}
+		got := argon2.IDKey([]byte(plain), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return false, false
+		}
+
+		if h.algorithm() != HashArgon2id {
+			return true, true
+		}
+		want := h.argon2Params()
+		weaker := params.Memory < want.Memory ||
+			params.Iterations < want.Iterations ||
+			params.Parallelism < want.Parallelism ||
+			uint32(len(salt)) < want.SaltLength ||
+			uint32(len(key)) < want.KeyLength
+		return true, weaker
+
+	case strings.HasPrefix(encoded, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain)) != nil {
+			return false, false
+		}
+
+		if h.algorithm() != HashBcrypt {
+			return true, true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return true, err != nil || cost < h.bcryptCost()
+
+	default:
+		return false, false
+	}
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errors.New("hash argon2id inválido")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errors.New("versión argon2 no soportada")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, errors.New("parámetros argon2id inválidos")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
 	if err != nil {
-		return "", err
+		return p, nil, nil, err
+	}
+	if len(key) == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, errors.New("hash argon2id inválido")
 	}
-	return string(b), nil
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
+
+// HashPassword usa el hasher por defecto (bcrypt, bcrypt.DefaultCost).
+func HashPassword(plain string) (string, error) {
+	return PasswordHasher{}.Hash(plain)
 }
 
+// CheckPassword verifica contra cualquier algoritmo soportado.
 func CheckPassword(hash, plain string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) == nil
+	ok, _ := PasswordHasher{}.Verify(hash, plain)
+	return ok
 }
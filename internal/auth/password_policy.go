@@ -0,0 +1,115 @@
+package auth
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsRaw string
+
+var commonPasswords = func() map[string]bool {
+	m := map[string]bool{}
+	for _, line := range strings.Split(commonPasswordsRaw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m[strings.ToLower(line)] = true
+	}
+	return m
+}()
+
+// PasswordPolicy define las reglas para contraseñas nuevas (registro, cambio, reset).
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int // bcrypt solo considera 72 bytes
+
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// No puede contener username ni la parte local del email
+	ForbidPersonalInfo bool
+	// No puede estar en la lista embebida de contraseñas comunes
+	ForbidCommon bool
+
+	// Cantidad de contraseñas anteriores que no se pueden reutilizar (0 = sin historial)
+	HistorySize int
+}
+
+// PolicyError lista todas las reglas que la contraseña no cumple.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "la contraseña no cumple la política: " + strings.Join(e.Violations, "; ")
+}
+
+// Validate revisa la contraseña contra la política. personal son datos del usuario
+// (username, email) que la contraseña no debe contener. Devuelve *PolicyError si falla.
+func (p PasswordPolicy) Validate(password string, personal ...string) error {
+	var v []string
+
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		v = append(v, fmt.Sprintf("debe tener al menos %d caracteres", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		v = append(v, fmt.Sprintf("debe tener como máximo %d bytes", p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		v = append(v, "debe incluir una mayúscula")
+	}
+	if p.RequireLower && !lower {
+		v = append(v, "debe incluir una minúscula")
+	}
+	if p.RequireDigit && !digit {
+		v = append(v, "debe incluir un número")
+	}
+	if p.RequireSymbol && !symbol {
+		v = append(v, "debe incluir un símbolo")
+	}
+
+	lowered := strings.ToLower(password)
+
+	if p.ForbidPersonalInfo {
+		for _, info := range personal {
+			info = strings.ToLower(strings.TrimSpace(info))
+			if i := strings.IndexByte(info, '@'); i >= 0 {
+				info = info[:i]
+			}
+			if len(info) >= 3 && strings.Contains(lowered, info) {
+				v = append(v, "no puede contener tu username o email")
+				break
+			}
+		}
+	}
+
+	if p.ForbidCommon && commonPasswords[lowered] {
+		v = append(v, "es demasiado común")
+	}
+
+	if len(v) > 0 {
+		return &PolicyError{Violations: v}
+	}
+	return nil
+}
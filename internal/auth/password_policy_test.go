@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:          10,
+		MaxLength:          72,
+		RequireUpper:       true,
+		RequireLower:       true,
+		RequireDigit:       true,
+		RequireSymbol:      true,
+		ForbidPersonalInfo: true,
+		ForbidCommon:       true,
+	}
+	personal := []string{"jperez", "juan.soto@example.com"}
+
+	cases := []struct {
+		name     string
+		password string
+		want     []string // violaciones esperadas; nil = aceptada
+	}{
+		{"aceptada", "Cordillera#2024", nil},
+		{"aceptada con unicode", "Ñandú-Veloz-99", nil},
+		{"corta", "Ab1#xyz", []string{"debe tener al menos 10 caracteres"}},
+		{"larga", "Aa1#" + strings.Repeat("x", 69), []string{"debe tener como máximo 72 bytes"}},
+		{"sin mayúscula", "cordillera#2024", []string{"debe incluir una mayúscula"}},
+		{"sin minúscula", "CORDILLERA#2024", []string{"debe incluir una minúscula"}},
+		{"sin número", "Cordillera#Sur", []string{"debe incluir un número"}},
+		{"sin símbolo", "Cordillera2024", []string{"debe incluir un símbolo"}},
+		{"contiene username", "Hola#JPerez2024", []string{"no puede contener tu username o email"}},
+		{"contiene parte local del email", "Juan.Soto#2024", []string{"no puede contener tu username o email"}},
+		{"común", "password", []string{
+			"debe tener al menos 10 caracteres",
+			"debe incluir una mayúscula",
+			"debe incluir un número",
+			"debe incluir un símbolo",
+			"es demasiado común",
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Validate(tc.password, personal...)
+			if tc.want == nil {
+				if err != nil {
+					t.Fatalf("rechazada: %v", err)
+				}
+				return
+			}
+
+			var pe *PolicyError
+			if !errors.As(err, &pe) {
+				t.Fatalf("err = %v, se esperaba *PolicyError", err)
+			}
+			if !reflect.DeepEqual(pe.Violations, tc.want) {
+				t.Fatalf("violaciones = %q, se esperaba %q", pe.Violations, tc.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyOptionalRules(t *testing.T) {
+	// Sin reglas activas solo cuenta el largo mínimo
+	policy := PasswordPolicy{MinLength: 8}
+
+	if err := policy.Validate("password", "password"); err != nil {
+		t.Fatalf("rechazada sin reglas activas: %v", err)
+	}
+	if err := policy.Validate("corta"); err == nil {
+		t.Fatal("aceptó una contraseña bajo el mínimo")
+	}
+
+	// Datos personales de menos de 3 caracteres no se consideran
+	policy.ForbidPersonalInfo = true
+	if err := policy.Validate("clave-de-jp-segura", "jp", "jp@example.com"); err != nil {
+		t.Fatalf("rechazada por un username corto: %v", err)
+	}
+}
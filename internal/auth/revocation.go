@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"handsoft/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// RevocationStore decide si un access token fue revocado antes de expirar.
+// Consulta Postgres, pero cachea en memoria para no ir a la DB en cada request:
+//   - un jti o una sesión revocada queda en cache hasta que el token expira
+//   - los resultados negativos y el corte por usuario se cachean CacheTTL
+//
+// Con varias instancias, una revocación hecha en otra instancia se ve a lo más CacheTTL después.
+type RevocationStore struct {
+	DB       *gorm.DB
+	CacheTTL time.Duration
+
+	mu        sync.Mutex
+	jtis      map[string]jtiEntry
+	users     map[uint]userEntry
+	services  map[uint]userEntry
+	sessions  map[uint]jtiEntry
+	lastSweep time.Time
+}
+
+// Una sesión revocada no vuelve a estar activa: se cachea por más tiempo que la vida de un access token
+const revokedSessionCache = 24 * time.Hour
+
+type jtiEntry struct {
+	revoked   bool
+	expiresAt time.Time // para revocados: expiración del token; para no revocados: fin del cache
+}
+
+type userEntry struct {
+	validAfter *time.Time
+	cachedTill time.Time
+}
+
+func NewRevocationStore(db *gorm.DB, cacheTTL time.Duration) *RevocationStore {
+	return &RevocationStore{
+		DB:       db,
+		CacheTTL: cacheTTL,
+		jtis:     map[string]jtiEntry{},
+		users:    map[uint]userEntry{},
+		services: map[uint]userEntry{},
+		sessions: map[uint]jtiEntry{},
+	}
+}
+
+// RevokeToken invalida un access token puntual (logout).
+func (s *RevocationStore) RevokeToken(claims *Claims, reason string) error {
+	if claims.ID == "" {
+		return errors.New("token sin jti")
+	}
+
+	exp := time.Now().Add(24 * time.Hour)
+	if claims.ExpiresAt != nil {
+		exp = claims.ExpiresAt.Time
+	}
+
+	rt := models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: exp,
+		Reason:    reason,
+	}
+	if err := s.DB.Where("jti = ?", claims.ID).FirstOrCreate(&rt).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.jtis[claims.ID] = jtiEntry{revoked: true, expiresAt: exp}
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeUser invalida todos los access tokens emitidos hasta ahora para el usuario
+// y revoca todas sus sesiones y refresh tokens ("cerrar sesión en todos lados").
+func (s *RevocationStore) RevokeUser(userID uint) error {
+	now := time.Now()
+	cutoff := revocationCutoff(now)
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Update("tokens_valid_after", cutoff).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.UserSession{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.users[userID] = userEntry{validAfter: &cutoff, cachedTill: now.Add(s.CacheTTL)}
+	s.mu.Unlock()
+	return nil
+}
+
+// InvalidateAccessTokens invalida los access tokens emitidos hasta ahora para el usuario, pero
+// conserva sus sesiones: el próximo refresh entrega un access token con los roles actuales.
+func (s *RevocationStore) InvalidateAccessTokens(userID uint) error {
+	now := time.Now()
+	cutoff := revocationCutoff(now)
+
+	if err := s.DB.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("tokens_valid_after", cutoff).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.users[userID] = userEntry{validAfter: &cutoff, cachedTill: now.Add(s.CacheTTL)}
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeServiceAccount invalida todos los access tokens emitidos hasta ahora para la service account
+// (desactivación, rotación de secret o cambio de roles).
+func (s *RevocationStore) RevokeServiceAccount(accountID uint) error {
+	now := time.Now()
+	cutoff := revocationCutoff(now)
+
+	if err := s.DB.Model(&models.ServiceAccount{}).
+		Where("id = ?", accountID).
+		Update("tokens_valid_after", cutoff).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.services[accountID] = userEntry{validAfter: &cutoff, cachedTill: now.Add(s.CacheTTL)}
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeFamily revoca una sesión completa: sus refresh tokens y los access tokens emitidos en ella.
+func (s *RevocationStore) RevokeFamily(familyID string) error {
+	now := time.Now()
+
+	var sess models.UserSession
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("family_id = ?", familyID).First(&sess).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.UserSession{}).
+			Where("id = ? AND revoked_at IS NULL", sess.ID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if sess.ID != 0 {
+		s.mu.Lock()
+		s.sessions[sess.ID] = jtiEntry{revoked: true, expiresAt: now.Add(revokedSessionCache)}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// IsRevoked indica si el token fue revocado por jti o por corte de usuario (o de service account).
+func (s *RevocationStore) IsRevoked(claims *Claims) (bool, error) {
+	now := time.Now()
+	s.sweep(now)
+
+	revoked, err := s.jtiRevoked(claims.ID, now)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	revoked, err = s.sessionRevoked(claims.SessionID, now)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	var validAfter *time.Time
+	if claims.IsService() {
+		validAfter, err = s.serviceValidAfter(claims.ServiceAccountID, now)
+	} else {
+		validAfter, err = s.userValidAfter(claims.UserID, now)
+	}
+	if err != nil {
+		return false, err
+	}
+	// El iat tiene resolución de segundos: un token emitido en el mismo segundo del corte también cae
+	if validAfter != nil && claims.IssuedAt != nil && !claims.IssuedAt.Time.After(*validAfter) {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// UserIssueTime devuelve el iat para un access token nuevo del usuario. Si el corte vigente aún no
+// pasó (revocación en este mismo segundo), el iat queda en el segundo siguiente al corte: el token
+// de reemplazo (refresh, SSO que sincronizó roles) no nace revocado y los anteriores siguen cayendo.
+func (s *RevocationStore) UserIssueTime(userID uint) (time.Time, error) {
+	now := time.Now()
+	validAfter, err := s.userValidAfter(userID, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return issueTimeAfter(now, validAfter), nil
+}
+
+// ServiceIssueTime es UserIssueTime para service accounts (ej: token pedido justo después de rotar el secret).
+func (s *RevocationStore) ServiceIssueTime(accountID uint) (time.Time, error) {
+	now := time.Now()
+	validAfter, err := s.serviceValidAfter(accountID, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return issueTimeAfter(now, validAfter), nil
+}
+
+// revocationCutoff redondea el corte al segundo siguiente. Todo token emitido hasta ahora tiene
+// iat <= corte, y IsRevoked lo rechaza.
+func revocationCutoff(now time.Time) time.Time {
+	cutoff := now.Truncate(time.Second)
+	if cutoff.Before(now) {
+		cutoff = cutoff.Add(time.Second)
+	}
+	return cutoff
+}
+
+func issueTimeAfter(now time.Time, validAfter *time.Time) time.Time {
+	if validAfter != nil && !now.Truncate(time.Second).After(*validAfter) {
+		return validAfter.Truncate(time.Second).Add(time.Second)
+	}
+	return now
+}
+
+func (s *RevocationStore) jtiRevoked(jti string, now time.Time) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	e, ok := s.jtis[jti]
+	s.mu.Unlock()
+	if ok && now.Before(e.expiresAt) {
+		return e.revoked, nil
+	}
+
+	var rt models.RevokedToken
+	err := s.DB.Where("jti = ?", jti).First(&rt).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	entry := jtiEntry{revoked: false, expiresAt: now.Add(s.CacheTTL)}
+	if err == nil {
+		entry = jtiEntry{revoked: true, expiresAt: rt.ExpiresAt}
+	}
+
+	s.mu.Lock()
+	s.jtis[jti] = entry
+	s.mu.Unlock()
+	return entry.revoked, nil
+}
+
+func (s *RevocationStore) sessionRevoked(sessionID uint, now time.Time) (bool, error) {
+	if sessionID == 0 {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	e, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if ok && now.Before(e.expiresAt) {
+		return e.revoked, nil
+	}
+
+	var sess models.UserSession
+	err := s.DB.Select("id", "revoked_at").First(&sess, sessionID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	// Una sesión borrada cuenta como revocada
+	entry := jtiEntry{revoked: false, expiresAt: now.Add(s.CacheTTL)}
+	if err != nil || sess.RevokedAt != nil {
+		entry = jtiEntry{revoked: true, expiresAt: now.Add(revokedSessionCache)}
+	}
+
+	s.mu.Lock()
+	s.sessions[sessionID] = entry
+	s.mu.Unlock()
+	return entry.revoked, nil
+}
+
+func (s *RevocationStore) userValidAfter(userID uint, now time.Time) (*time.Time, error) {
+	s.mu.Lock()
+	e, ok := s.users[userID]
+	s.mu.Unlock()
+	if ok && now.Before(e.cachedTill) {
+		return e.validAfter, nil
+	}
+
+	// Unscoped: un usuario borrado (lógico) no tiene tokens válidos
+	var u models.User
+	err := s.DB.Unscoped().Select("id", "tokens_valid_after", "deleted_at").First(&u, userID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	validAfter := u.TokensValidAfter
+	if u.DeletedAt.Valid {
+		validAfter = &u.DeletedAt.Time
+	}
+
+	s.mu.Lock()
+	s.users[userID] = userEntry{validAfter: validAfter, cachedTill: now.Add(s.CacheTTL)}
+	s.mu.Unlock()
+	return validAfter, nil
+}
+
+func (s *RevocationStore) serviceValidAfter(accountID uint, now time.Time) (*time.Time, error) {
+	s.mu.Lock()
+	e, ok := s.services[accountID]
+	s.mu.Unlock()
+	if ok && now.Before(e.cachedTill) {
+		return e.validAfter, nil
+	}
+
+	var sa models.ServiceAccount
+	err := s.DB.Select("id", "tokens_valid_after").First(&sa, accountID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Una service account borrada no tiene tokens válidos
+	validAfter := sa.TokensValidAfter
+	if err != nil {
+		validAfter = &now
+	}
+
+	s.mu.Lock()
+	s.services[accountID] = userEntry{validAfter: validAfter, cachedTill: now.Add(s.CacheTTL)}
+	s.mu.Unlock()
+	return validAfter, nil
+}
+
+// sweep limpia entradas vencidas del cache (como mucho una vez por CacheTTL).
+func (s *RevocationStore) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) < s.CacheTTL {
+		return
+	}
+	s.lastSweep = now
+
+	for k, e := range s.jtis {
+		if !now.Before(e.expiresAt) {
+			delete(s.jtis, k)
+		}
+	}
+	for k, e := range s.users {
+		if !now.Before(e.cachedTill) {
+			delete(s.users, k)
+		}
+	}
+	for k, e := range s.services {
+		if !now.Before(e.cachedTill) {
+			delete(s.services, k)
+		}
+	}
+	for k, e := range s.sessions {
+		if !now.Before(e.expiresAt) {
+			delete(s.sessions, k)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secreto de los vectores de RFC 4226/6238 (SHA1): "12345678901234567890" en ASCII.
+var rfcSecret = b32.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTPRFC4226(t *testing.T) {
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+	for counter, code := range want {
+		if got := hotp([]byte("12345678901234567890"), int64(counter)); got != code {
+			t.Errorf("hotp(%d) = %s, se esperaba %s", counter, got, code)
+		}
+	}
+}
+
+// Vectores SHA1 del apéndice B de RFC 6238. La RFC publica 8 dígitos; con 6 dígitos son los
+// últimos 6 (mismo truncado dinámico, módulo 10^6).
+func TestVerifyTOTPRFC6238(t *testing.T) {
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tc := range cases {
+		at := time.Unix(tc.unix, 0)
+		code := tc.code[len(tc.code)-totpDigits:]
+
+		step, ok := VerifyTOTP(rfcSecret, code, at)
+		if !ok {
+			t.Errorf("t=%d: %s rechazado", tc.unix, code)
+			continue
+		}
+		if step != tc.unix/totpPeriod {
+			t.Errorf("t=%d: paso = %d, se esperaba %d", tc.unix, step, tc.unix/totpPeriod)
+		}
+	}
+}
+
+func TestVerifyTOTPWindow(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	step := at.Unix() / totpPeriod
+	code := "005924" // paso de 1234567890 (RFC 6238)
+
+	cases := []struct {
+		name  string
+		shift int64 // pasos entre el código y el reloj del servidor
+		ok    bool
+	}{
+		{"mismo paso", 0, true},
+		{"un paso atrás", -1, true},
+		{"un paso adelante", 1, true},
+		{"dos pasos atrás", -2, false},
+		{"dos pasos adelante", 2, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := at.Add(time.Duration(tc.shift*totpPeriod) * time.Second)
+			got, ok := VerifyTOTP(rfcSecret, code, now)
+			if ok != tc.ok {
+				t.Fatalf("ok = %v, se esperaba %v", ok, tc.ok)
+			}
+			// Devuelve el paso del código, no el del reloj: con eso se rechaza el replay
+			if ok && got != step {
+				t.Fatalf("paso = %d, se esperaba %d", got, step)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPInput(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+
+	if _, ok := VerifyTOTP(rfcSecret, " 005 924 ", at); !ok {
+		t.Error("código con espacios rechazado")
+	}
+	if _, ok := VerifyTOTP(strings.ToLower(rfcSecret), "005924", at); !ok {
+		t.Error("secreto en minúsculas rechazado")
+	}
+	for _, code := range []string{"", "05924", "0005924", "005925"} {
+		if _, ok := VerifyTOTP(rfcSecret, code, at); ok {
+			t.Errorf("código %q aceptado", code)
+		}
+	}
+	if _, ok := VerifyTOTP("no-es-base32!", "005924", at); ok {
+		t.Error("secreto inválido aceptado")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("formato inesperado: %q", code)
+		}
+		if seen[code] {
+			t.Errorf("código repetido: %q", code)
+		}
+		seen[code] = true
+
+		// El usuario puede tipearlo en mayúsculas, sin guion o con espacios
+		loose := " " + strings.ToUpper(strings.Replace(code, "-", " ", 1)) + " "
+		if HashRecoveryCode(loose) != HashRecoveryCode(code) {
+			t.Errorf("%q y %q no normalizan igual", loose, code)
+		}
+	}
+}
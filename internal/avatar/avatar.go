@@ -0,0 +1,93 @@
+// Package avatar valida fotos de perfil y genera las miniaturas estándar (JPEG cuadrado).
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	_ "image/png" // registra el decoder PNG
+	"net/http"
+	"slices"
+
+	"golang.org/x/image/draw"
+)
+
+// Límites de la imagen subida
+const (
+	MaxBytes  = 5 << 20
+	MaxPixels = 16_000_000 // evita "bombas" de descompresión (dimensiones enormes en pocos bytes)
+)
+
+// Sizes son los lados (px) de las miniaturas generadas, de menor a mayor.
+var Sizes = []int{64, 128, 256}
+
+// ContentTypes aceptados (se valida el contenido real, no solo el header)
+var ContentTypes = []string{"image/jpeg", "image/png"}
+
+var (
+	ErrTooLarge        = errors.New("la imagen supera el tamaño máximo")
+	ErrUnsupportedType = errors.New("formato no soportado (usa JPEG o PNG)")
+	ErrInvalidImage    = errors.New("la imagen no se pudo leer")
+	ErrTooManyPixels   = errors.New("la imagen tiene dimensiones demasiado grandes")
+)
+
+// Thumbnails valida la imagen y devuelve un JPEG por cada tamaño de Sizes (recortada al centro).
+// declaredType es el Content-Type informado por el cliente ("" = no informado).
+// Al re-codificar se descartan los metadatos (EXIF con GPS, etc.).
+func Thumbnails(data []byte, declaredType string) (map[int][]byte, error) {
+	if len(data) > MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	sniffed := http.DetectContentType(data)
+	if !slices.Contains(ContentTypes, sniffed) {
+		return nil, ErrUnsupportedType
+	}
+	if declaredType != "" && declaredType != sniffed {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	// La más grande sale del original; las demás de la anterior (más rápido y sin diferencia visible)
+	out := map[int][]byte{}
+	current := src
+	for i := len(Sizes) - 1; i >= 0; i-- {
+		thumb := squareThumbnail(current, Sizes[i])
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		out[Sizes[i]] = buf.Bytes()
+		current = thumb
+	}
+	return out, nil
+}
+
+// squareThumbnail recorta el cuadrado central de src y lo escala a size x size (Catmull-Rom,
+// que al reducir promedia toda el área). La transparencia se compone sobre fondo blanco.
+func squareThumbnail(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	crop := image.Rect(x0, y0, x0+side, y0+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+	return dst
+}
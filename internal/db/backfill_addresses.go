@@ -0,0 +1,15 @@
+package db
+
+import "gorm.io/gorm"
+
+// BackfillUserAddresses crea la UserAddress por defecto ("home") para usuarios que solo
+// tienen users.address_id (creados antes de las direcciones múltiples). Es idempotente.
+func BackfillUserAddresses(gdb *gorm.DB) error {
+	return gdb.Exec(`
+		INSERT INTO user_addresses (created_at, updated_at, user_id, address_id, label, is_default)
+		SELECT NOW(), NOW(), u.id, u.address_id, 'home', true
+		FROM users u
+		WHERE u.address_id IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM user_addresses ua WHERE ua.user_id = u.id)
+	`).Error
+}
@@ -0,0 +1,20 @@
+package db
+
+import "gorm.io/gorm"
+
+// BackfillEmailVerified marca como verificados los emails de las cuentas creadas antes de la
+// verificación de email; si no, UNVERIFIED_LOGIN_POLICY=block|grace las dejaría sin acceso.
+// Se reconocen porque nunca recibieron un token de verificación. Corre una sola vez (RunOnce):
+// después, un email sin verificar es un dato real (ej: un admin cambió el email).
+func BackfillEmailVerified(gdb *gorm.DB) error {
+	return RunOnce(gdb, "backfill_email_verified", func(tx *gorm.DB) error {
+		return tx.Exec(`
+			UPDATE users u
+			SET email_verified_at = u.created_at
+			WHERE u.email_verified_at IS NULL
+			  AND NOT EXISTS (
+				SELECT 1 FROM user_tokens t WHERE t.user_id = u.id AND t.purpose = 'email_verification'
+			  )
+		`).Error
+	})
+}
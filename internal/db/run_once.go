@@ -0,0 +1,26 @@
+package db
+
+import (
+	"time"
+
+	"handsoft/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RunOnce aplica fn una sola vez por base de datos (queda registrado en data_migrations).
+// Corre en una transacción con la fila tomada, así dos instancias que parten juntas no la repiten.
+func RunOnce(gdb *gorm.DB, name string, fn func(tx *gorm.DB) error) error {
+	return gdb.Transaction(func(tx *gorm.DB) error {
+		m := models.DataMigration{Name: name, AppliedAt: time.Now()}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&m)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		return fn(tx)
+	})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errCommuneNotFound = errors.New("commune_id no existe")
+
+// AddressInput son los campos de una dirección tal como llegan en los requests.
+type AddressInput struct {
+	CommuneID    uint   `json:"commune_id" binding:"required"`
+	Street       string `json:"street" binding:"required"`
+	StreetNumber string `json:"street_number" binding:"required"`
+
+	IsCondominium          bool   `json:"is_condominium"`
+	CondominiumHouseNumber string `json:"condominium_house_number"`
+	BuildingNumber         string `json:"building_number"`
+	ApartmentNumber        string `json:"apartment_number"`
+	Extra                  string `json:"extra"`
+}
+
+func (a *AddressInput) normalize() {
+	a.Street = strings.TrimSpace(a.Street)
+	a.StreetNumber = strings.TrimSpace(a.StreetNumber)
+	a.CondominiumHouseNumber = strings.TrimSpace(a.CondominiumHouseNumber)
+	a.BuildingNumber = strings.TrimSpace(a.BuildingNumber)
+	a.ApartmentNumber = strings.TrimSpace(a.ApartmentNumber)
+	a.Extra = strings.TrimSpace(a.Extra)
+}
+
+// findOrCreateAddress reutiliza una dirección EXACTAMENTE igual si ya existe o crea una nueva.
+// Las direcciones se comparten entre usuarios: nunca se editan, se reemplaza el AddressID.
+func findOrCreateAddress(tx *gorm.DB, in AddressInput) (models.Address, error) {
+	in.normalize()
+
+	// Validar que la comuna exista (y por ende ciudad/región/país se infiere)
+	var commune models.Commune
+	if err := tx.First(&commune, in.CommuneID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Address{}, errCommuneNotFound
+		}
+		return models.Address{}, err
+	}
+
+	var addr models.Address
+	addrQuery := tx.Where(`
+		commune_id = ? AND
+		street = ? AND
+		street_number = ? AND
+		is_condominium = ? AND
+		COALESCE(condominium_house_number,'') = ? AND
+		COALESCE(building_number,'') = ? AND
+		COALESCE(apartment_number,'') = ? AND
+		COALESCE(extra,'') = ?
+	`,
+		in.CommuneID,
+		in.Street,
+		in.StreetNumber,
+		in.IsCondominium,
+		in.CondominiumHouseNumber,
+		in.BuildingNumber,
+		in.ApartmentNumber,
+		in.Extra,
+	)
+
+	err := addrQuery.First(&addr).Error
+	if err == nil {
+		return addr, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Address{}, err
+	}
+
+	// No existe -> crear
+	addr = models.Address{
+		CommuneID:              in.CommuneID,
+		Street:                 in.Street,
+		StreetNumber:           in.StreetNumber,
+		IsCondominium:          in.IsCondominium,
+		CondominiumHouseNumber: in.CondominiumHouseNumber,
+		BuildingNumber:         in.BuildingNumber,
+		ApartmentNumber:        in.ApartmentNumber,
+		Extra:                  in.Extra,
+	}
+	if err := tx.Create(&addr).Error; err != nil {
+		return models.Address{}, err
+	}
+	return addr, nil
+}
+
+func addressJSON(a models.Address) gin.H {
+	return gin.H{
+		"id":                       a.ID,
+		"street":                   a.Street,
+		"street_number":            a.StreetNumber,
+		"is_condominium":           a.IsCondominium,
+		"condominium_house_number": a.CondominiumHouseNumber,
+		"building_number":          a.BuildingNumber,
+		"apartment_number":         a.ApartmentNumber,
+		"extra":                    a.Extra,
+		"commune_id":               a.CommuneID,
+	}
+}
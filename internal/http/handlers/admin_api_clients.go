@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/http/middleware"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createAPIClientReq struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func apiClientJSON(a models.APIClient) gin.H {
+	return gin.H{
+		"id":           a.ID,
+		"name":         a.Name,
+		"key_prefix":   a.KeyPrefix,
+		"scopes":       a.ScopeList(),
+		"created_at":   a.CreatedAt,
+		"expires_at":   a.ExpiresAt,
+		"last_used_at": a.LastUsedAt,
+		"revoked_at":   a.RevokedAt,
+	}
+}
+
+func (h *AdminHandler) ListAPIClients(c *gin.Context) {
+	var clients []models.APIClient
+	if err := h.DB.Order("id asc").Find(&clients).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(clients))
+	for _, a := range clients {
+		out = append(out, apiClientJSON(a))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// CreateAPIClient emite una API key nueva. La key plana se devuelve solo esta vez.
+func (h *AdminHandler) CreateAPIClient(c *gin.Context) {
+	var req createAPIClientReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_body"})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name_required"})
+		return
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, s := range req.Scopes {
+		s = strings.TrimSpace(s)
+		if s != middleware.APIScopeAll && !slices.Contains(middleware.KnownAPIScopes, s) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown_scope", "scope": s})
+			return
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scopes_required"})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at_in_past"})
+		return
+	}
+
+	plain, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_generate_key"})
+		return
+	}
+	// El prefijo hace reconocible la key (ej: en escáneres de secretos); el hash incluye el prefijo
+	key := "hsk_" + plain
+
+	client := models.APIClient{
+		Name:      req.Name,
+		KeyPrefix: key[:12],
+		KeyHash:   auth.HashOpaqueToken(key),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := h.DB.Create(&client).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_create_api_client"})
+		return
+	}
+
+	out := apiClientJSON(client)
+	out["api_key"] = key
+	c.JSON(http.StatusCreated, out)
+}
+
+// RevokeAPIClient revoca la key (tarda a lo más el TTL del cache del middleware en aplicar).
+func (h *AdminHandler) RevokeAPIClient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return
+	}
+
+	res := h.DB.Model(&models.APIClient{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_revoke_api_client"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api_client_not_found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
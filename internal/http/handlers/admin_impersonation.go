@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/http/middleware"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const impersonationTTL = 15 * time.Minute
+
+type impersonateReq struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// Impersonate emite un access token corto para actuar como otro usuario (soporte).
+// No se puede impersonar a uno mismo, a usuarios inactivos ni a otros super admins.
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return
+	}
+
+	var req impersonateReq
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason_required"})
+		return
+	}
+
+	actorID := c.GetUint(middleware.CtxUserIDKey)
+	if actorID == uint(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot_impersonate_self"})
+		return
+	}
+
+	var u models.User
+	if err := h.DB.Preload("Roles").First(&u, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user_not_found"})
+		return
+	}
+	if !u.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_inactive"})
+		return
+	}
+
+	roles := make([]string, 0, len(u.Roles))
+	for _, r := range u.Roles {
+		if r.IsSuperAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot_impersonate_super_admin"})
+			return
+		}
+		roles = append(roles, r.Name)
+	}
+
+	token, err := auth.SignImpersonationToken(h.JWTConfig, actorID, u.ID, roles, impersonationTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_sign_token"})
+		return
+	}
+
+	entry := models.AuditLog{
+		Action:        "impersonation.start",
+		ActorID:       &actorID,
+		SubjectUserID: &u.ID,
+		Method:        c.Request.Method,
+		Path:          c.Request.URL.Path,
+		Status:        http.StatusOK,
+		IP:            c.ClientIP(),
+		UserAgent:     c.Request.UserAgent(),
+		Detail:        strings.TrimSpace(req.Reason),
+	}
+	if v, ok := c.Get(middleware.CtxAPIClientKey); ok {
+		if info, ok := v.(middleware.APIClientInfo); ok && info.ID != 0 {
+			entry.APIClientID = &info.ID
+		}
+	}
+	// Sin registro de auditoría no se entrega el token
+	if err := h.DB.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_write_audit_log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  token,
+		"token_type":    "Bearer",
+		"expires_in":    int(impersonationTTL.Seconds()),
+		"impersonating": gin.H{"id": u.ID, "email": u.Email, "username": u.Username},
+		"actor_id":      actorID,
+	})
+}
+
+// ListAuditLogs lista el registro de auditoría.
+// Filtros opcionales: ?action=, ?actor_id=, ?subject_user_id=, ?limit= (máx 500).
+func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
+	q := h.DB.Model(&models.AuditLog{})
+
+	if v := strings.TrimSpace(c.Query("action")); v != "" {
+		q = q.Where("action = ?", v)
+	}
+	for _, f := range []string{"actor_id", "subject_user_id"} {
+		if v := c.Query(f); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_" + f})
+				return
+			}
+			q = q.Where(f+" = ?", n)
+		}
+	}
+
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_limit"})
+			return
+		}
+		limit = min(n, 500)
+	}
+
+	var logs []models.AuditLog
+	if err := q.Order("id desc").Limit(limit).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(logs))
+	for _, l := range logs {
+		out = append(out, gin.H{
+			"id":              l.ID,
+			"created_at":      l.CreatedAt,
+			"action":          l.Action,
+			"actor_id":        l.ActorID,
+			"subject_user_id": l.SubjectUserID,
+			"api_client_id":   l.APIClientID,
+			"method":          l.Method,
+			"path":            l.Path,
+			"status":          l.Status,
+			"ip":              l.IP,
+			"user_agent":      l.UserAgent,
+			"detail":          l.Detail,
+		})
+	}
+
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/http/middleware"
+	"handsoft/internal/mail"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type createInvitationReq struct {
+	Email       string   `json:"email" binding:"required,email"`
+	Roles       []string `json:"roles"`
+	SpaceID     *uint    `json:"space_id"`
+	WarehouseID *uint    `json:"warehouse_id"`
+}
+
+func invitationJSON(inv models.Invitation, now time.Time) gin.H {
+	roles := make([]string, 0, len(inv.Roles))
+	for _, r := range inv.Roles {
+		roles = append(roles, r.Name)
+	}
+
+	status := "pending"
+	switch {
+	case inv.AcceptedAt != nil:
+		status = "accepted"
+	case inv.RevokedAt != nil:
+		status = "revoked"
+	case !now.Before(inv.ExpiresAt):
+		status = "expired"
+	}
+
+	return gin.H{
+		"id":               inv.ID,
+		"email":            inv.Email,
+		"roles":            roles,
+		"space_id":         inv.SpaceID,
+		"warehouse_id":     inv.WarehouseID,
+		"status":           status,
+		"invited_by_id":    inv.InvitedByID,
+		"created_at":       inv.CreatedAt,
+		"expires_at":       inv.ExpiresAt,
+		"accepted_at":      inv.AcceptedAt,
+		"accepted_user_id": inv.AcceptedUserID,
+		"revoked_at":       inv.RevokedAt,
+	}
+}
+
+// validateInvitationScope comprueba que el espacio/bodega existan y sean coherentes entre sí.
+func validateInvitationScope(db *gorm.DB, spaceID, warehouseID *uint) (string, error) {
+	if spaceID != nil {
+		var count int64
+		if err := db.Model(&models.Space{}).Where("id = ?", *spaceID).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return "space_not_found", nil
+		}
+	}
+
+	if warehouseID != nil {
+		var w models.Warehouse
+		if err := db.Select("id", "space_id").First(&w, *warehouseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "warehouse_not_found", nil
+			}
+			return "", err
+		}
+		if spaceID != nil && w.SpaceID != *spaceID {
+			return "warehouse_not_in_space", nil
+		}
+	}
+
+	return "", nil
+}
+
+// issueInvitationToken asigna un token nuevo a la invitación (renueva la expiración) y envía el correo.
+// Devuelve si el correo salió; la invitación queda creada igual (se puede reenviar).
+func (h *AdminHandler) issueInvitationToken(ctx context.Context, inv *models.Invitation) (bool, error) {
+	plain, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return false, err
+	}
+
+	inv.TokenHash = hash
+	inv.ExpiresAt = time.Now().Add(h.InvitationTTL)
+	if err := h.DB.Model(inv).Updates(map[string]any{
+		"token_hash": inv.TokenHash,
+		"expires_at": inv.ExpiresAt,
+	}).Error; err != nil {
+		return false, err
+	}
+
+	link := h.AppURL + "/accept-invitation?token=" + url.QueryEscape(plain)
+	err = h.Mailer.Send(ctx, mail.Message{
+		To:      []string{inv.Email},
+		Subject: "Te invitaron a Handsoft",
+		Text: fmt.Sprintf(
+			"Hola,\n\nTe invitaron a crear una cuenta. Abre el siguiente link para elegir tu usuario y contraseña (válido por %d horas):\n\n%s\n\nSi no esperabas esta invitación, ignora este correo.\n",
+			int(h.InvitationTTL.Hours()), link,
+		),
+	})
+	if err != nil {
+		log.Printf("invitation %d: no se pudo enviar correo: %v", inv.ID, err)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (h *AdminHandler) findInvitation(c *gin.Context) (*models.Invitation, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return nil, false
+	}
+
+	var inv models.Invitation
+	if err := h.DB.Preload("Roles").First(&inv, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invitation_not_found"})
+		return nil, false
+	}
+	return &inv, true
+}
+
+// ListInvitations lista invitaciones. Filtros opcionales: ?email=, ?status=pending|accepted|revoked|expired.
+func (h *AdminHandler) ListInvitations(c *gin.Context) {
+	now := time.Now()
+	q := h.DB.Model(&models.Invitation{}).Preload("Roles")
+
+	if v := strings.TrimSpace(c.Query("email")); v != "" {
+		q = q.Where("email = ?", strings.ToLower(v))
+	}
+	switch c.Query("status") {
+	case "":
+	case "pending":
+		q = q.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	case "accepted":
+		q = q.Where("accepted_at IS NOT NULL")
+	case "revoked":
+		q = q.Where("revoked_at IS NOT NULL")
+	case "expired":
+		q = q.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_status"})
+		return
+	}
+
+	var invs []models.Invitation
+	if err := q.Order("id desc").Limit(500).Find(&invs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(invs))
+	for _, inv := range invs {
+		out = append(out, invitationJSON(inv, now))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// CreateInvitation invita a un email con roles (default: rol base "user") y alcance opcional.
+// Invitaciones pendientes anteriores para el mismo email quedan revocadas.
+func (h *AdminHandler) CreateInvitation(c *gin.Context) {
+	var req createInvitationReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_body"})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	// Unscoped: el email de un usuario borrado (lógico) sigue ocupado y la invitación no se podría aceptar
+	var count int64
+	if err := h.DB.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "email_already_registered"})
+		return
+	}
+
+	roles, err := rolesByName(h.DB, req.Roles)
+	if err != nil {
+		if errors.Is(err, errUnknownRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+	if len(roles) == 0 {
+		role, err := baseUserRole(h.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_create_base_role"})
+			return
+		}
+		roles = []models.Role{role}
+	}
+
+	code, err := validateInvitationScope(h.DB, req.SpaceID, req.WarehouseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+	if code != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": code})
+		return
+	}
+
+	// Token provisorio único; issueInvitationToken lo reemplaza por el definitivo
+	_, placeholder, err := auth.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_generate_token"})
+		return
+	}
+
+	inv := models.Invitation{
+		Email:       email,
+		TokenHash:   placeholder,
+		Roles:       roles,
+		SpaceID:     req.SpaceID,
+		WarehouseID: req.WarehouseID,
+		ExpiresAt:   time.Now().Add(h.InvitationTTL),
+	}
+	if actorID := c.GetUint(middleware.CtxUserIDKey); actorID != 0 {
+		inv.InvitedByID = &actorID
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Invitation{}).
+			Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", email).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&inv).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_create_invitation"})
+		return
+	}
+
+	sent, err := h.issueInvitationToken(c.Request.Context(), &inv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_generate_token"})
+		return
+	}
+
+	out := invitationJSON(inv, time.Now())
+	out["email_sent"] = sent
+	c.JSON(http.StatusCreated, out)
+}
+
+// ResendInvitation genera un token nuevo (el anterior deja de servir) y reenvía el correo.
+func (h *AdminHandler) ResendInvitation(c *gin.Context) {
+	inv, ok := h.findInvitation(c)
+	if !ok {
+		return
+	}
+	if inv.AcceptedAt != nil || inv.RevokedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "invitation_not_pending"})
+		return
+	}
+
+	sent, err := h.issueInvitationToken(c.Request.Context(), inv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_generate_token"})
+		return
+	}
+
+	out := invitationJSON(*inv, time.Now())
+	out["email_sent"] = sent
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *AdminHandler) RevokeInvitation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return
+	}
+
+	res := h.DB.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_revoke_invitation"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invitation_not_found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
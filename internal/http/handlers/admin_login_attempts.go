@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListLoginAttempts lista el historial de intentos de login.
+// Filtros opcionales: ?user_id=, ?login=, ?ip=, ?success=true|false, ?limit= (máx 500).
+func (h *AdminHandler) ListLoginAttempts(c *gin.Context) {
+	q := h.DB.Model(&models.LoginAttempt{})
+
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_user_id"})
+			return
+		}
+		q = q.Where("user_id = ?", id)
+	}
+	if v := strings.TrimSpace(c.Query("login")); v != "" {
+		q = q.Where("login = ?", strings.ToLower(v))
+	}
+	if v := strings.TrimSpace(c.Query("ip")); v != "" {
+		q = q.Where("ip = ?", v)
+	}
+	if v := c.Query("success"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_success"})
+			return
+		}
+		q = q.Where("success = ?", b)
+	}
+
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_limit"})
+			return
+		}
+		limit = min(n, 500)
+	}
+
+	var attempts []models.LoginAttempt
+	if err := q.Order("id desc").Limit(limit).Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(attempts))
+	for _, a := range attempts {
+		out = append(out, gin.H{
+			"id":         a.ID,
+			"created_at": a.CreatedAt,
+			"login":      a.Login,
+			"user_id":    a.UserID,
+			"ip":         a.IP,
+			"user_agent": a.UserAgent,
+			"success":    a.Success,
+			"reason":     a.Reason,
+		})
+	}
+
+	c.JSON(http.StatusOK, out)
+}
@@ -2,16 +2,25 @@ package handlers
 
 import (
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"handsoft/internal/models"
+	"handsoft/internal/permissions"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ListPermissions lista los permisos agrupables por módulo. Con ?module= filtra uno.
 func (h *AdminHandler) ListPermissions(c *gin.Context) {
+	q := h.DB.Model(&models.Permission{})
+	if v := strings.TrimSpace(c.Query("module")); v != "" {
+		q = q.Where("module = ?", v)
+	}
+
 	var perms []models.Permission
-	if err := h.DB.Order("id asc").Find(&perms).Error; err != nil {
+	if err := q.Order("module asc, code asc").Find(&perms).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
 		return
 	}
@@ -31,7 +40,26 @@ func (h *AdminHandler) GetRolePermissions(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, role.Permissions)
+	// ?effective=true suma los permisos heredados de los roles padre
+	if c.Query("effective") != "true" {
+		c.JSON(http.StatusOK, role.Permissions)
+		return
+	}
+
+	ids, err := permissions.EffectiveRoleIDs(h.DB, []string{role.Name})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+	var perms []models.Permission
+	if err := h.DB.
+		Where("id IN (?)", h.DB.Table("role_permissions").Select("permission_id").Where("role_id IN ?", ids)).
+		Order("module asc, code asc").
+		Find(&perms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+	c.JSON(http.StatusOK, perms)
 }
 
 type setRolePermsReq struct {
@@ -57,21 +85,52 @@ func (h *AdminHandler) SetRolePermissions(c *gin.Context) {
 		return
 	}
 
+	// Solo códigos declarados en el catálogo (internal/permissions)
+	codes := make([]string, 0, len(req.PermissionCodes))
+	unknown := make([]string, 0)
+	for _, code := range req.PermissionCodes {
+		code = strings.TrimSpace(code)
+		if slices.Contains(codes, code) {
+			continue
+		}
+		if !permissions.Known(code) {
+			unknown = append(unknown, code)
+			continue
+		}
+		codes = append(codes, code)
+	}
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown_permission", "codes": unknown})
+		return
+	}
+
 	var perms []models.Permission
-	if len(req.PermissionCodes) > 0 {
-		if err := h.DB.Where("code IN ?", req.PermissionCodes).Find(&perms).Error; err != nil {
+	if len(codes) > 0 {
+		if err := h.DB.Where("code IN ?", codes).Find(&perms).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
 			return
 		}
+		// El catálogo se sincroniza al arrancar; si falta alguno la DB quedó desfasada
+		if len(perms) != len(codes) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "permissions_not_synced"})
+			return
+		}
 	}
 
-	if err := h.DB.Model(&role).Association("Permissions").Replace(perms); err != nil {
+	assoc := h.DB.Model(&role).Association("Permissions")
+	if len(perms) == 0 {
+		err = assoc.Clear()
+	} else {
+		err = assoc.Replace(perms)
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_set_permissions"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"role_id":  role.ID,
-		"assigned": len(perms),
+		"role_id":     role.ID,
+		"assigned":    len(perms),
+		"permissions": codes,
 	})
 }
@@ -1,24 +1,47 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"handsoft/internal/auth"
+	"handsoft/internal/mail"
 	"handsoft/internal/models"
+	"handsoft/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type AdminHandler struct {
-	DB *gorm.DB
+	DB          *gorm.DB
+	JWTConfig   auth.JWTConfig
+	Revocations *auth.RevocationStore
+
+	// Correos (invitaciones, reset forzado de contraseña)
+	Mailer           mail.Sender
+	AppURL           string
+	InvitationTTL    time.Duration
+	PasswordResetTTL time.Duration
+
+	Hasher auth.PasswordHasher
+
+	// Archivos de usuarios (fotos de perfil)
+	Storage storage.Store
 }
 
 type createRoleReq struct {
 	Name         string `json:"name" binding:"required"`
 	Description  string `json:"description"`
 	IsSuperAdmin bool   `json:"is_super_admin"`
+	RequiresMFA  bool   `json:"requires_mfa"`
+
+	// Rol padre opcional (hereda sus permisos)
+	ParentID *uint `json:"parent_id"`
 }
 
 func (h *AdminHandler) ListRoles(c *gin.Context) {
@@ -47,6 +70,15 @@ func (h *AdminHandler) CreateRole(c *gin.Context) {
 		Name:         req.Name,
 		Description:  req.Description,
 		IsSuperAdmin: req.IsSuperAdmin,
+		RequiresMFA:  req.RequiresMFA,
+	}
+
+	if req.ParentID != nil {
+		if err := checkRoleParent(h.DB, 0, *req.ParentID); err != nil {
+			roleParentError(c, err)
+			return
+		}
+		role.ParentID = req.ParentID
 	}
 
 	if err := h.DB.Create(&role).Error; err != nil {
@@ -61,6 +93,7 @@ type updateRoleReq struct {
 	Name         *string `json:"name"`
 	Description  *string `json:"description"`
 	IsSuperAdmin *bool   `json:"is_super_admin"`
+	RequiresMFA  *bool   `json:"requires_mfa"`
 }
 
 func (h *AdminHandler) UpdateRole(c *gin.Context) {
@@ -93,18 +126,60 @@ func (h *AdminHandler) UpdateRole(c *gin.Context) {
 	if req.Description != nil {
 		role.Description = *req.Description
 	}
+	superChanged := req.IsSuperAdmin != nil && *req.IsSuperAdmin != role.IsSuperAdmin
 	if req.IsSuperAdmin != nil {
 		role.IsSuperAdmin = *req.IsSuperAdmin
 	}
+	if req.RequiresMFA != nil {
+		role.RequiresMFA = *req.RequiresMFA
+	}
 
-	if err := h.DB.Save(&role).Error; err != nil {
+	// Quitar el flag super admin a un rol no puede dejar el sistema sin super admins
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if superChanged {
+			if err := lockSuperAdminRoles(tx); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&role).Error; err != nil {
+			return err
+		}
+		if superChanged && !role.IsSuperAdmin {
+			return ensureSuperAdminRemains(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errLastSuperAdmin) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot_update_role"})
 		return
 	}
 
+	// Los usuarios con el rol ganan o pierden privilegios: sus access tokens vigentes dejan de servir
+	if superChanged {
+		h.invalidateRoleHolders(role.ID)
+	}
+
 	c.JSON(http.StatusOK, role)
 }
 
+// invalidateRoleHolders invalida los access tokens de los usuarios con el rol (errores solo se loguean).
+func (h *AdminHandler) invalidateRoleHolders(roleID uint) {
+	var userIDs []uint
+	if err := h.DB.Table("user_roles").Where("role_id = ?", roleID).Pluck("user_id", &userIDs).Error; err != nil {
+		log.Printf("admin: no se pudieron listar usuarios del rol %d: %v", roleID, err)
+		return
+	}
+	for _, id := range userIDs {
+		if err := h.Revocations.InvalidateAccessTokens(id); err != nil {
+			log.Printf("admin: no se pudieron invalidar tokens de user %d: %v", id, err)
+		}
+	}
+}
+
 func (h *AdminHandler) DeleteRole(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -124,6 +199,17 @@ func (h *AdminHandler) DeleteRole(c *gin.Context) {
 		return
 	}
 
+	// Los hijos perderían en silencio los permisos heredados: primero hay que reasignarlos
+	var children int64
+	if err := h.DB.Model(&models.Role{}).Where("parent_id = ?", role.ID).Count(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+	if children > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "role_has_children", "children": children})
+		return
+	}
+
 	if err := h.DB.Delete(&models.Role{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_delete_role"})
 		return
@@ -131,3 +217,130 @@ func (h *AdminHandler) DeleteRole(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+var (
+	errParentNotFound = errors.New("parent_not_found")
+	errRoleCycle      = errors.New("role_cycle")
+)
+
+// checkRoleParent valida que parentID exista y que asignarlo como padre de roleID no arme un ciclo
+// (roleID = 0 para un rol nuevo, que no puede estar en ninguna cadena).
+func checkRoleParent(db *gorm.DB, roleID, parentID uint) error {
+	if parentID == roleID {
+		return errRoleCycle
+	}
+
+	seen := map[uint]bool{}
+	current := parentID
+	for {
+		var r models.Role
+		if err := db.Select("id", "parent_id").First(&r, current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) && current == parentID {
+				return errParentNotFound
+			}
+			return err
+		}
+		seen[r.ID] = true
+
+		if r.ParentID == nil {
+			return nil
+		}
+		// Subiendo desde el padre se llega al mismo rol (o a un ciclo que ya existía)
+		if *r.ParentID == roleID || seen[*r.ParentID] {
+			return errRoleCycle
+		}
+		current = *r.ParentID
+	}
+}
+
+func roleParentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errParentNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent_not_found"})
+	case errors.Is(err, errRoleCycle):
+		c.JSON(http.StatusConflict, gin.H{"error": "role_cycle"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+	}
+}
+
+type setRoleParentReq struct {
+	// null = quitar el padre
+	ParentID *uint `json:"parent_id"`
+}
+
+// SetRoleParent asigna (o quita, con parent_id null) el rol padre del que se heredan permisos.
+func (h *AdminHandler) SetRoleParent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return
+	}
+
+	var req setRoleParentReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_body"})
+		return
+	}
+
+	var role models.Role
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock de la tabla en modo exclusivo para escrituras: dos cambios cruzados en paralelo
+		// (A->B y B->A) podrían pasar cada uno su validación y dejar un ciclo
+		if err := tx.Exec("LOCK TABLE roles IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		if err := tx.First(&role, id).Error; err != nil {
+			return err
+		}
+		if req.ParentID != nil {
+			if err := checkRoleParent(tx, role.ID, *req.ParentID); err != nil {
+				return err
+			}
+		}
+		role.ParentID = req.ParentID
+		return tx.Model(&role).Update("parent_id", req.ParentID).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "role_not_found"})
+			return
+		}
+		roleParentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+var errUnknownRole = errors.New("unknown_role")
+
+// rolesByName resuelve roles por nombre (ignora vacíos). Falla con errUnknownRole si alguno no existe.
+func rolesByName(db *gorm.DB, names []string) ([]models.Role, error) {
+	clean := make([]string, 0, len(names))
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			clean = append(clean, n)
+		}
+	}
+	if len(clean) == 0 {
+		return []models.Role{}, nil
+	}
+
+	var roles []models.Role
+	if err := db.Where("name IN ?", clean).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+	for _, r := range roles {
+		found[r.Name] = true
+	}
+	for _, n := range clean {
+		if !found[n] {
+			return nil, errUnknownRole
+		}
+	}
+
+	return roles, nil
+}
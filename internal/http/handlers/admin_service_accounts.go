@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/http/middleware"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type createServiceAccountReq struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+}
+
+type setServiceAccountRolesReq struct {
+	Roles []string `json:"roles"`
+}
+
+var errSuperAdminRole = errors.New("super_admin_role_not_allowed")
+
+func serviceAccountJSON(sa models.ServiceAccount) gin.H {
+	roles := make([]string, 0, len(sa.Roles))
+	for _, r := range sa.Roles {
+		roles = append(roles, r.Name)
+	}
+
+	return gin.H{
+		"id":           sa.ID,
+		"name":         sa.Name,
+		"description":  sa.Description,
+		"client_id":    sa.ClientID,
+		"is_active":    sa.IsActive,
+		"roles":        roles,
+		"created_at":   sa.CreatedAt,
+		"last_used_at": sa.LastUsedAt,
+	}
+}
+
+// serviceAccountRoles resuelve los roles por nombre. Roles super admin no se permiten:
+// una integración nunca debería saltarse los permisos.
+func serviceAccountRoles(db *gorm.DB, names []string) ([]models.Role, error) {
+	roles, err := rolesByName(db, names)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		if r.IsSuperAdmin {
+			return nil, errSuperAdminRole
+		}
+	}
+	return roles, nil
+}
+
+func respondServiceAccountRolesError(c *gin.Context, err error) {
+	if errors.Is(err, errUnknownRole) || errors.Is(err, errSuperAdminRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+}
+
+// newServiceAccountSecret genera el secret (se devuelve una sola vez) y su hash.
+func newServiceAccountSecret() (string, string, error) {
+	plain, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	secret := "hss_" + plain
+	return secret, auth.HashOpaqueToken(secret), nil
+}
+
+func (h *AdminHandler) findServiceAccount(c *gin.Context) (*models.ServiceAccount, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return nil, false
+	}
+
+	var sa models.ServiceAccount
+	if err := h.DB.Preload("Roles").First(&sa, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "service_account_not_found"})
+		return nil, false
+	}
+	return &sa, true
+}
+
+func (h *AdminHandler) ListServiceAccounts(c *gin.Context) {
+	var accounts []models.ServiceAccount
+	if err := h.DB.Preload("Roles").Order("id asc").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(accounts))
+	for _, sa := range accounts {
+		out = append(out, serviceAccountJSON(sa))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// CreateServiceAccount crea la cuenta y devuelve client_id + client_secret (el secret solo esta vez).
+func (h *AdminHandler) CreateServiceAccount(c *gin.Context) {
+	var req createServiceAccountReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_body"})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name_required"})
+		return
+	}
+
+	roles, err := serviceAccountRoles(h.DB, req.Roles)
+	if err != nil {
+		respondServiceAccountRolesError(c, err)
+		return
+	}
+
+	rid, err := auth.NewRandomID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_generate_secret"})
+		return
+	}
+	secret, hash, err := newServiceAccountSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_generate_secret"})
+		return
+	}
+
+	sa := models.ServiceAccount{
+		Name:        req.Name,
+		Description: strings.TrimSpace(req.Description),
+		ClientID:    "sa_" + rid,
+		SecretHash:  hash,
+		IsActive:    true,
+		Roles:       roles,
+	}
+	if actorID := c.GetUint(middleware.CtxUserIDKey); actorID != 0 {
+		sa.CreatedByID = &actorID
+	}
+
+	if err := h.DB.Create(&sa).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot_create_service_account"})
+		return
+	}
+
+	out := serviceAccountJSON(sa)
+	out["client_secret"] = secret
+	c.JSON(http.StatusCreated, out)
+}
+
+// SetServiceAccountRoles reemplaza los roles. Los tokens vigentes se invalidan para que
+// los nuevos roles apliquen de inmediato.
+func (h *AdminHandler) SetServiceAccountRoles(c *gin.Context) {
+	sa, ok := h.findServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	var req setServiceAccountRolesReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_body"})
+		return
+	}
+
+	roles, err := serviceAccountRoles(h.DB, req.Roles)
+	if err != nil {
+		respondServiceAccountRolesError(c, err)
+		return
+	}
+
+	if err := h.DB.Model(sa).Association("Roles").Replace(roles); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_update_roles"})
+		return
+	}
+	sa.Roles = roles
+
+	if err := h.Revocations.RevokeServiceAccount(sa.ID); err != nil {
+		log.Printf("service account %d: no se pudieron invalidar tokens: %v", sa.ID, err)
+	}
+
+	c.JSON(http.StatusOK, serviceAccountJSON(*sa))
+}
+
+// RotateServiceAccountSecret emite un secret nuevo; el anterior y sus tokens dejan de servir.
+func (h *AdminHandler) RotateServiceAccountSecret(c *gin.Context) {
+	sa, ok := h.findServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	secret, hash, err := newServiceAccountSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_generate_secret"})
+		return
+	}
+
+	if err := h.DB.Model(sa).Update("secret_hash", hash).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_rotate_secret"})
+		return
+	}
+	if err := h.Revocations.RevokeServiceAccount(sa.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_revoke_tokens"})
+		return
+	}
+
+	out := serviceAccountJSON(*sa)
+	out["client_secret"] = secret
+	c.JSON(http.StatusOK, out)
+}
+
+// DisableServiceAccount desactiva la cuenta e invalida sus tokens (se conserva para auditoría).
+func (h *AdminHandler) DisableServiceAccount(c *gin.Context) {
+	sa, ok := h.findServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.Model(sa).Updates(map[string]any{
+		"is_active":  false,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_disable_service_account"})
+		return
+	}
+	if err := h.Revocations.RevokeServiceAccount(sa.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_revoke_tokens"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"handsoft/internal/http/middleware"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type userRolesReq struct {
+	Roles []string `json:"roles"`
+}
+
+var errLastSuperAdmin = errors.New("last_super_admin")
+var errSuperAdminRequired = errors.New("super_admin_required")
+var errPermissionEscalation = errors.New("permission_escalation")
+
+// lockSuperAdminRoles bloquea las filas de roles super admin hasta el fin de la transacción,
+// para que dos cambios concurrentes no dejen el sistema sin super admins.
+func lockSuperAdminRoles(tx *gorm.DB) error {
+	var ids []uint
+	return tx.Model(&models.Role{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("is_super_admin = ?", true).
+		Pluck("id", &ids).Error
+}
+
+// ensureSuperAdminRemains falla con errLastSuperAdmin si no queda ningún usuario activo
+// (no borrado) con un rol super admin. Se llama dentro de la transacción, después del cambio.
+func ensureSuperAdminRemains(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Model(&models.User{}).
+		Where("is_active = ?", true).
+		Where(`id IN (
+			SELECT ur.user_id FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE r.is_super_admin = ?
+		)`, true).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errLastSuperAdmin
+	}
+	return nil
+}
+
+// callerIsSuperAdmin indica si quien hace el request tiene un rol super admin (según el JWT).
+func (h *AdminHandler) callerIsSuperAdmin(c *gin.Context) (bool, error) {
+	names, _ := c.Get(middleware.CtxRolesKey)
+	roleNames, _ := names.([]string)
+	if len(roleNames) == 0 {
+		return false, nil
+	}
+
+	var count int64
+	err := h.DB.Model(&models.Role{}).
+		Where("name IN ? AND is_super_admin = ?", roleNames, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// guardSuperAdminTarget responde 403 si el usuario objetivo tiene un rol super admin y quien
+// hace el request no lo es: cambiar su email, resetear su contraseña, borrarlo, etc. equivale a
+// tomar la cuenta. Devuelve false si ya respondió.
+func (h *AdminHandler) guardSuperAdminTarget(c *gin.Context, userID uint) bool {
+	var count int64
+	if err := h.DB.Table("user_roles ur").
+		Joins("JOIN roles r ON r.id = ur.role_id").
+		Where("ur.user_id = ? AND r.is_super_admin = ?", userID, true).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return false
+	}
+	if count == 0 {
+		return true
+	}
+
+	isSuper, err := h.callerIsSuperAdmin(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return false
+	}
+	if !isSuper {
+		c.JSON(http.StatusForbidden, gin.H{"error": errSuperAdminRequired.Error()})
+		return false
+	}
+	return true
+}
+
+// changeUserRoles calcula los roles nuevos con apply y los guarda, protegiendo al último
+// super admin. Solo un super admin puede dar o quitar roles super admin, y nadie más puede dar
+// roles con permisos que no tiene.
+// Los access tokens vigentes del usuario se invalidan (sus sesiones siguen: el refresh trae los roles nuevos).
+func (h *AdminHandler) changeUserRoles(c *gin.Context, apply func(current []models.Role) ([]models.Role, error)) {
+	u, ok := h.findUser(c, "Roles")
+	if !ok {
+		return
+	}
+	if !h.guardSuperAdminTarget(c, u.ID) {
+		return
+	}
+
+	grantor, err := h.callerGrantor(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	var next []models.Role
+	var missing []string
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockSuperAdminRoles(tx); err != nil {
+			return err
+		}
+
+		next, err = apply(u.Roles)
+		if err != nil {
+			return err
+		}
+
+		if !grantor.isSuper && superAdminChanged(u.Roles, next) {
+			return errSuperAdminRequired
+		}
+
+		// Solo se revisan los roles nuevos: quitar roles no da permisos
+		var added []models.Role
+		for _, r := range next {
+			if !hasRole(u.Roles, r.ID) {
+				added = append(added, r)
+			}
+		}
+		missing, err = grantor.missing(tx, added)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return errPermissionEscalation
+		}
+
+		assoc := tx.Model(u).Association("Roles")
+		if len(next) == 0 {
+			err = assoc.Clear()
+		} else {
+			err = assoc.Replace(next)
+		}
+		if err != nil {
+			return err
+		}
+
+		return ensureSuperAdminRemains(tx)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnknownRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errSuperAdminRequired):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, errPermissionEscalation):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "permissions": missing})
+		case errors.Is(err, errLastSuperAdmin):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_update_roles"})
+		}
+		return
+	}
+
+	if err := h.Revocations.InvalidateAccessTokens(u.ID); err != nil {
+		log.Printf("admin: no se pudieron invalidar tokens de user %d: %v", u.ID, err)
+	}
+
+	names := make([]string, 0, len(next))
+	for _, r := range next {
+		names = append(names, r.Name)
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": u.ID, "roles": names})
+}
+
+// superAdminChanged indica si el cambio da o quita algún rol super admin.
+func superAdminChanged(before, after []models.Role) bool {
+	for _, r := range before {
+		if r.IsSuperAdmin && !hasRole(after, r.ID) {
+			return true
+		}
+	}
+	for _, r := range after {
+		if r.IsSuperAdmin && !hasRole(before, r.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUserRoles reemplaza todos los roles del usuario.
+func (h *AdminHandler) SetUserRoles(c *gin.Context) {
+	var req userRolesReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_body"})
+		return
+	}
+
+	h.changeUserRoles(c, func(_ []models.Role) ([]models.Role, error) {
+		return rolesByName(h.DB, req.Roles)
+	})
+}
+
+// AddUserRoles agrega roles sin tocar los que ya tiene.
+func (h *AdminHandler) AddUserRoles(c *gin.Context) {
+	var req userRolesReq
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Roles) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_body"})
+		return
+	}
+
+	h.changeUserRoles(c, func(current []models.Role) ([]models.Role, error) {
+		add, err := rolesByName(h.DB, req.Roles)
+		if err != nil {
+			return nil, err
+		}
+
+		next := append([]models.Role{}, current...)
+		for _, r := range add {
+			if !hasRole(next, r.ID) {
+				next = append(next, r)
+			}
+		}
+		return next, nil
+	})
+}
+
+// RemoveUserRole quita un rol (por nombre). Quitar un rol que no tiene no es error.
+func (h *AdminHandler) RemoveUserRole(c *gin.Context) {
+	name := strings.TrimSpace(c.Param("role"))
+
+	h.changeUserRoles(c, func(current []models.Role) ([]models.Role, error) {
+		if _, err := rolesByName(h.DB, []string{name}); err != nil {
+			return nil, err
+		}
+
+		next := make([]models.Role, 0, len(current))
+		for _, r := range current {
+			if r.Name != name {
+				next = append(next, r)
+			}
+		}
+		return next, nil
+	})
+}
+
+func hasRole(list []models.Role, id uint) bool {
+	for _, r := range list {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/http/middleware"
+	"handsoft/internal/models"
+	"handsoft/internal/rut"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type updateUserReq struct {
+	IsActive *bool   `json:"is_active"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+	Username *string `json:"username" binding:"omitempty,min=3,max=32,excludes=@"`
+	RUT      *string `json:"rut"` // "" lo borra; se valida con normalizeRUT
+}
+
+func adminUserJSON(u models.User) gin.H {
+	roles := make([]string, 0, len(u.Roles))
+	for _, r := range u.Roles {
+		roles = append(roles, r.Name)
+	}
+
+	return gin.H{
+		"id":                u.ID,
+		"email":             u.Email,
+		"username":          u.Username,
+		"rut":               u.RUT,
+		"full_name":         u.Contacts.FullName,
+		"is_active":         u.IsActive,
+		"roles":             roles,
+		"email_verified_at": u.EmailVerifiedAt,
+		"mfa_enabled":       u.TOTPEnabledAt != nil,
+		"locked_until":      u.LockedUntil,
+		"created_at":        u.CreatedAt,
+		"updated_at":        u.UpdatedAt,
+	}
+}
+
+// filteredUsers arma la consulta de usuarios con los filtros del query string (ver ListUsers).
+// Si un filtro es inválido responde 400 y devuelve false.
+func (h *AdminHandler) filteredUsers(c *gin.Context) (*gorm.DB, bool) {
+	q := h.DB.Model(&models.User{})
+
+	if v := strings.TrimSpace(c.Query("email")); v != "" {
+		q = q.Where("users.email ILIKE ?", "%"+strings.ToLower(v)+"%")
+	}
+	if v := strings.TrimSpace(c.Query("username")); v != "" {
+		q = q.Where("users.username ILIKE ?", "%"+v+"%")
+	}
+	if v := strings.TrimSpace(c.Query("rut")); v != "" {
+		value, err := rut.Normalize(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_rut"})
+			return nil, false
+		}
+		q = q.Where("users.rut = ?", value)
+	}
+	if v := strings.TrimSpace(c.Query("role")); v != "" {
+		q = q.Where(`users.id IN (
+			SELECT ur.user_id FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE r.name = ?
+		)`, v)
+	}
+	if v := c.Query("active"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_active"})
+			return nil, false
+		}
+		q = q.Where("users.is_active = ?", b)
+	}
+	if v := c.Query("commune_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_commune_id"})
+			return nil, false
+		}
+		q = q.Where("users.commune_id = ? OR users.address_id IN (SELECT id FROM addresses WHERE commune_id = ?)", id, id)
+	}
+
+	return q, true
+}
+
+// ListUsers lista usuarios paginados.
+// Filtros opcionales: ?email=, ?username= (contiene), ?rut= (exacto, con o sin puntos), ?role= (nombre), ?active=true|false,
+// ?commune_id= (comuna del usuario o de su dirección). Paginación: ?page= (desde 1), ?page_size= (máx 100).
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	q, ok := h.filteredUsers(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := 1, 20
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_page"})
+			return
+		}
+		page = n
+	}
+	if v := c.Query("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_page_size"})
+			return
+		}
+		pageSize = min(n, 100)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	var users []models.User
+	if err := q.Preload("Roles").Preload("Contacts").
+		Order("users.id asc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	items := make([]gin.H, 0, len(users))
+	for _, u := range users {
+		items = append(items, adminUserJSON(u))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":     items,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+func (h *AdminHandler) findUser(c *gin.Context, preloads ...string) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return nil, false
+	}
+
+	q := h.DB
+	for _, p := range preloads {
+		q = q.Preload(p)
+	}
+
+	var u models.User
+	if err := q.First(&u, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user_not_found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return nil, false
+	}
+	return &u, true
+}
+
+// GetUser devuelve el detalle del usuario (roles, contacto, teléfonos, dirección).
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	u, ok := h.findUser(c, "Roles", "Contacts", "Phones", "Address", "Address.Commune")
+	if !ok {
+		return
+	}
+
+	out := adminUserJSON(*u)
+
+	phones := make([]gin.H, 0, len(u.Phones))
+	for _, p := range u.Phones {
+		phones = append(phones, gin.H{
+			"id":      p.ID,
+			"label":   p.Label,
+			"number":  p.Number,
+			"is_main": p.IsMain,
+		})
+	}
+	out["phones"] = phones
+
+	var address any = nil
+	if u.Address != nil {
+		address = gin.H{
+			"id":                       u.Address.ID,
+			"street":                   u.Address.Street,
+			"street_number":            u.Address.StreetNumber,
+			"is_condominium":           u.Address.IsCondominium,
+			"condominium_house_number": u.Address.CondominiumHouseNumber,
+			"building_number":          u.Address.BuildingNumber,
+			"apartment_number":         u.Address.ApartmentNumber,
+			"extra":                    u.Address.Extra,
+			"commune_id":               u.Address.CommuneID,
+			"commune":                  u.Address.Commune.Name,
+		}
+	}
+	out["address"] = address
+	out["commune_id"] = u.CommuneID
+	out["failed_login_count"] = u.FailedLoginCount
+
+	c.JSON(http.StatusOK, out)
+}
+
+// UpdateUser cambia IsActive, email y/o username. Desactivar cierra todas las sesiones;
+// un email nuevo queda sin verificar.
+func (h *AdminHandler) UpdateUser(c *gin.Context) {
+	u, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+	if !h.guardSuperAdminTarget(c, u.ID) {
+		return
+	}
+
+	var req updateUserReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_body"})
+		return
+	}
+
+	updates := map[string]any{}
+
+	if req.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*req.Email))
+		if email != u.Email {
+			if taken, err := userFieldTaken(h.DB, "email", email, u.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+				return
+			} else if taken {
+				c.JSON(http.StatusConflict, gin.H{"error": "email_taken"})
+				return
+			}
+			updates["email"] = email
+			updates["email_verified_at"] = nil
+		}
+	}
+
+	if req.Username != nil {
+		username := strings.TrimSpace(*req.Username)
+		if username != u.Username {
+			if len(username) < 3 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "username_too_short"})
+				return
+			}
+			if taken, err := userFieldTaken(h.DB, "username", username, u.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+				return
+			} else if taken {
+				c.JSON(http.StatusConflict, gin.H{"error": "username_taken"})
+				return
+			}
+			updates["username"] = username
+		}
+	}
+
+	if req.RUT != nil {
+		value, err := normalizeRUT(*req.RUT)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_rut"})
+			return
+		}
+		if value != nil {
+			if taken, err := userFieldTaken(h.DB, "rut", *value, u.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+				return
+			} else if taken {
+				c.JSON(http.StatusConflict, gin.H{"error": "rut_taken"})
+				return
+			}
+		}
+		updates["rut"] = value
+	}
+
+	deactivate := false
+	if req.IsActive != nil && *req.IsActive != u.IsActive {
+		if !*req.IsActive && u.ID == c.GetUint(middleware.CtxUserIDKey) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot_deactivate_self"})
+			return
+		}
+		updates["is_active"] = *req.IsActive
+		deactivate = !*req.IsActive
+	}
+
+	if len(updates) > 0 {
+		err := h.DB.Transaction(func(tx *gorm.DB) error {
+			if err := lockSuperAdminRoles(tx); err != nil {
+				return err
+			}
+			if err := tx.Model(u).Updates(updates).Error; err != nil {
+				return err
+			}
+			if deactivate {
+				return ensureSuperAdminRemains(tx)
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, errLastSuperAdmin) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_update_user"})
+			return
+		}
+	}
+
+	if deactivate {
+		if err := h.Revocations.RevokeUser(u.ID); err != nil {
+			log.Printf("admin: no se pudieron revocar sesiones de user %d: %v", u.ID, err)
+		}
+	}
+
+	var fresh models.User
+	if err := h.DB.Preload("Roles").Preload("Contacts").First(&fresh, u.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+	c.JSON(http.StatusOK, adminUserJSON(fresh))
+}
+
+// userFieldTaken indica si otro usuario (incluidos los borrados) ya usa el valor.
+func userFieldTaken(db *gorm.DB, field, value string, exceptID uint) (bool, error) {
+	var count int64
+	err := db.Unscoped().Model(&models.User{}).
+		Where(field+" = ? AND id <> ?", value, exceptID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ForcePasswordReset invalida la contraseña actual, cierra todas las sesiones y envía un link de reset.
+func (h *AdminHandler) ForcePasswordReset(c *gin.Context) {
+	u, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+	if !h.guardSuperAdminTarget(c, u.ID) {
+		return
+	}
+
+	// Hash de una contraseña aleatoria que nadie conoce: solo se entra con el link
+	random, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_generate_token"})
+		return
+	}
+	hash, err := h.Hasher.Hash(random)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_reset_password"})
+		return
+	}
+
+	var token string
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", u.ID).
+			Update("password_hash", hash).Error; err != nil {
+			return err
+		}
+		token, err = createUserToken(tx, u.ID, models.TokenPurposePasswordReset, h.PasswordResetTTL)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_reset_password"})
+		return
+	}
+
+	if err := h.Revocations.RevokeUser(u.ID); err != nil {
+		log.Printf("admin: no se pudieron revocar sesiones de user %d: %v", u.ID, err)
+	}
+
+	msg := passwordResetMessage(h.AppURL, h.PasswordResetTTL, *u, token,
+		"Un administrador solicitó que cambies tu contraseña; la anterior ya no sirve.")
+	sent := true
+	if err := h.Mailer.Send(c.Request.Context(), msg); err != nil {
+		log.Printf("admin: no se pudo enviar reset a user %d: %v", u.ID, err)
+		sent = false
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"email_sent": sent})
+}
+
+// DeleteUser hace borrado lógico: desactiva, cierra sesiones y oculta al usuario.
+// Email y username quedan reservados.
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	u, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+	if !h.guardSuperAdminTarget(c, u.ID) {
+		return
+	}
+	if u.ID == c.GetUint(middleware.CtxUserIDKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot_delete_self"})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockSuperAdminRoles(tx); err != nil {
+			return err
+		}
+		if err := tx.Model(u).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(u).Error; err != nil {
+			return err
+		}
+		return ensureSuperAdminRemains(tx)
+	})
+	if err != nil {
+		if errors.Is(err, errLastSuperAdmin) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_delete_user"})
+		return
+	}
+
+	if err := h.Revocations.RevokeUser(u.ID); err != nil {
+		log.Printf("admin: no se pudieron revocar sesiones de user %d: %v", u.ID, err)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeUserTokens cierra todas las sesiones de un usuario (access + refresh tokens).
+func (h *AdminHandler) RevokeUserTokens(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return
+	}
+
+	var u models.User
+	if err := h.DB.Select("id").First(&u, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user_not_found"})
+		return
+	}
+
+	if err := h.Revocations.RevokeUser(u.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_revoke_tokens"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UnlockUser levanta el bloqueo por intentos fallidos y reinicia los contadores.
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return
+	}
+
+	res := h.DB.Model(&models.User{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"failed_login_count":   0,
+			"last_failed_login_at": nil,
+			"locked_until":         nil,
+		})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_unlock_user"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user_not_found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,596 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Columnas del CSV de usuarios (import y export usan el mismo formato).
+// roles va separado por "|"; commune acepta id o nombre (el export escribe el nombre).
+var userCSVColumns = []string{"email", "username", "full_name", "phone", "rut", "commune", "roles"}
+
+const (
+	userImportMaxBytes = 5 << 20
+	userImportMaxRows  = 1000
+	userCSVRoleSep     = "|"
+)
+
+var errImportTooLarge = errors.New("file_too_large")
+
+// userImportRow es una fila ya validada y normalizada.
+type userImportRow struct {
+	Line      int
+	Email     string
+	Username  string
+	FullName  string
+	Phone     string
+	RUT       *string
+	CommuneID *uint
+	Roles     []models.Role
+}
+
+// value devuelve el valor de un campo único de la fila ("" si no viene).
+func (r userImportRow) value(field string) string {
+	switch field {
+	case "email":
+		return r.Email
+	case "username":
+		return r.Username
+	case "rut":
+		if r.RUT != nil {
+			return *r.RUT
+		}
+	}
+	return ""
+}
+
+type userImportError struct {
+	Row   int    `json:"row"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportUsers crea usuarios en lote desde un CSV (multipart "file" o el body con Content-Type text/csv).
+// Primero valida todas las filas; si alguna falla responde 422 con los errores por fila y no crea nada.
+// Con ?dry_run=true solo valida. Si todo está bien, crea todos los usuarios en una transacción
+// (sin contraseña) y envía a cada uno un link para definirla.
+func (h *AdminHandler) ImportUsers(c *gin.Context) {
+	dryRun := false
+	if v := c.Query("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_dry_run"})
+			return
+		}
+		dryRun = b
+	}
+
+	data, err := readImportFile(c)
+	if err != nil {
+		if errors.Is(err, errImportTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_file"})
+		return
+	}
+
+	grantor, err := h.callerGrantor(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	rows, rowErrs, err := h.parseUserImport(data, grantor)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rowErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"dry_run": dryRun,
+			"rows":    len(rows),
+			"errors":  rowErrs,
+		})
+		return
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run": true,
+			"rows":    len(rows),
+			"errors":  []userImportError{},
+		})
+		return
+	}
+
+	baseRole, err := baseUserRole(h.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	created := make([]models.User, 0, len(rows))
+	tokens := make([]string, 0, len(rows))
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		for _, r := range rows {
+			u := models.User{
+				Email:        r.Email,
+				Username:     r.Username,
+				PasswordHash: auth.UnusablePasswordHash,
+				IsActive:     true,
+				RUT:          r.RUT,
+				CommuneID:    r.CommuneID,
+				Contacts:     models.Contact{FullName: r.FullName},
+				Roles:        r.Roles,
+			}
+			if len(u.Roles) == 0 {
+				u.Roles = []models.Role{baseRole}
+			}
+			if r.Phone != "" {
+				u.Phones = []models.UserPhone{{Label: "mobile", Number: r.Phone, IsMain: true}}
+			}
+			if err := tx.Create(&u).Error; err != nil {
+				return fmt.Errorf("fila %d: %w", r.Line, err)
+			}
+
+			// Link para definir contraseña: dura lo mismo que una invitación
+			token, err := createUserToken(tx, u.ID, models.TokenPurposePasswordReset, h.InvitationTTL)
+			if err != nil {
+				return err
+			}
+			created = append(created, u)
+			tokens = append(tokens, token)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("admin: import de usuarios falló: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_import_users"})
+		return
+	}
+
+	emailsFailed := 0
+	for i, u := range created {
+		msg := passwordResetMessage(h.AppURL, h.InvitationTTL, u, tokens[i],
+			"Un administrador creó tu cuenta; define tu contraseña para ingresar.")
+		if err := h.Mailer.Send(c.Request.Context(), msg); err != nil {
+			log.Printf("admin: no se pudo enviar bienvenida a user %d: %v", u.ID, err)
+			emailsFailed++
+		}
+	}
+
+	ids := make([]uint, 0, len(created))
+	for _, u := range created {
+		ids = append(ids, u.ID)
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"dry_run":       false,
+		"rows":          len(rows),
+		"created":       len(created),
+		"user_ids":      ids,
+		"emails_failed": emailsFailed,
+	})
+}
+
+// ExportUsers descarga los usuarios como CSV (mismas columnas que el import, mismos filtros que ListUsers).
+func (h *AdminHandler) ExportUsers(c *gin.Context) {
+	q, ok := h.filteredUsers(c)
+	if !ok {
+		return
+	}
+
+	filename := "users-" + time.Now().Format("20060102-150405") + ".csv"
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(userCSVColumns); err != nil {
+		return
+	}
+
+	var batch []models.User
+	err := q.Preload("Roles").Preload("Contacts").Preload("Phones").
+		Preload("Commune").Preload("Address.Commune").
+		Order("users.id asc").
+		FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+			for _, u := range batch {
+				if err := w.Write(userCSVRecord(u)); err != nil {
+					return err
+				}
+			}
+			w.Flush()
+			return w.Error()
+		}).Error
+	if err != nil {
+		// Los headers ya salieron: solo se puede cortar el archivo y loguear
+		log.Printf("admin: export de usuarios falló: %v", err)
+		return
+	}
+	w.Flush()
+}
+
+func userCSVRecord(u models.User) []string {
+	roles := make([]string, 0, len(u.Roles))
+	for _, r := range u.Roles {
+		roles = append(roles, r.Name)
+	}
+
+	phone := ""
+	for _, p := range u.Phones {
+		if p.IsMain || phone == "" {
+			phone = p.Number
+		}
+	}
+
+	commune := ""
+	if u.Commune != nil {
+		commune = u.Commune.Name
+	} else if u.Address != nil {
+		commune = u.Address.Commune.Name
+	}
+
+	rutValue := ""
+	if u.RUT != nil {
+		rutValue = *u.RUT
+	}
+
+	record := []string{
+		u.Email,
+		u.Username,
+		u.Contacts.FullName,
+		phone,
+		rutValue,
+		commune,
+		strings.Join(roles, userCSVRoleSep),
+	}
+	for i, v := range record {
+		record[i] = csvSafeCell(v)
+	}
+	return record
+}
+
+// csvFormulaPrefixes son los primeros caracteres con que Excel/LibreOffice interpretan una celda
+// como fórmula. El nombre y el username los escribe el propio usuario (PATCH /me).
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvSafeCell antepone ' a las celdas que se abrirían como fórmula (CSV injection).
+func csvSafeCell(v string) string {
+	if v != "" && strings.ContainsRune(csvFormulaPrefixes, rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// csvUnsafeCell revierte csvSafeCell al importar, para que un export se pueda re-importar tal cual.
+func csvUnsafeCell(v string) string {
+	if len(v) > 1 && v[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(v[1])) {
+		return v[1:]
+	}
+	return v
+}
+
+// readImportFile lee el CSV desde el campo multipart "file" o desde el body.
+func readImportFile(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, userImportMaxBytes+1<<20)
+
+	var r io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return nil, errImportTooLarge
+			}
+			return nil, err
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, userImportMaxBytes+1))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, errImportTooLarge
+		}
+		return nil, err
+	}
+	if len(data) > userImportMaxBytes {
+		return nil, errImportTooLarge
+	}
+
+	// Excel agrega BOM al guardar como "CSV UTF-8"
+	return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), nil
+}
+
+// parseUserImport valida el CSV completo. Devuelve error solo si el archivo en sí es inválido
+// (encabezado, formato, tamaño); los problemas de cada fila van en la lista de errores.
+func (h *AdminHandler) parseUserImport(data []byte, grantor *roleGrantor) ([]userImportRow, []userImportError, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectCSVDelimiter(data)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.New("missing_header")
+	}
+
+	cols := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, col := range userCSVColumns {
+			if col == name {
+				known = true
+			}
+		}
+		if !known {
+			return nil, nil, fmt.Errorf("unknown_column: %s", name)
+		}
+		if _, dup := cols[name]; dup {
+			return nil, nil, fmt.Errorf("duplicate_column: %s", name)
+		}
+		cols[name] = i
+	}
+	for _, required := range []string{"email", "username"} {
+		if _, ok := cols[required]; !ok {
+			return nil, nil, fmt.Errorf("missing_column: %s", required)
+		}
+	}
+
+	lookup, err := h.newImportLookup(grantor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []userImportRow
+	var rowErrs []userImportError
+	seen := map[string]int{} // "campo:valor" -> línea donde apareció primero
+
+	for {
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid_csv: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if len(rows) >= userImportMaxRows {
+			return nil, nil, fmt.Errorf("too_many_rows: máximo %d", userImportMaxRows)
+		}
+
+		get := func(col string) string {
+			if i, ok := cols[col]; ok && i < len(rec) {
+				return strings.TrimSpace(csvUnsafeCell(rec[i]))
+			}
+			return ""
+		}
+
+		var errs []userImportError
+		fail := func(field, msg string) {
+			errs = append(errs, userImportError{Row: line, Field: field, Error: msg})
+		}
+		unique := func(field, value string) {
+			key := field + ":" + strings.ToLower(value)
+			if first, ok := seen[key]; ok {
+				fail(field, fmt.Sprintf("duplicado en el archivo (fila %d)", first))
+				return
+			}
+			seen[key] = line
+		}
+
+		row := userImportRow{
+			Line:     line,
+			Email:    strings.ToLower(get("email")),
+			Username: get("username"),
+			FullName: get("full_name"),
+			Phone:    get("phone"),
+		}
+
+		if err := lookup.validate.Var(row.Email, "required,email"); err != nil {
+			fail("email", "email inválido")
+		} else {
+			unique("email", row.Email)
+		}
+
+		if n := len([]rune(row.Username)); n < 3 || n > 32 {
+			fail("username", "username debe tener entre 3 y 32 caracteres")
+		} else if strings.Contains(row.Username, "@") {
+			// El login busca por email o username: un username con @ podría ser el email de otra cuenta
+			fail("username", "username no puede contener @")
+		} else {
+			unique("username", row.Username)
+		}
+
+		if len([]rune(row.FullName)) > 120 {
+			fail("full_name", "máximo 120 caracteres")
+		}
+		if len(row.Phone) > 32 {
+			fail("phone", "máximo 32 caracteres")
+		}
+
+		if raw := get("rut"); raw != "" {
+			if value, err := normalizeRUT(raw); err != nil {
+				fail("rut", err.Error())
+			} else {
+				row.RUT = value
+				unique("rut", *value)
+			}
+		}
+
+		if raw := get("commune"); raw != "" {
+			if id, err := lookup.commune(raw); err != nil {
+				fail("commune", err.Error())
+			} else {
+				row.CommuneID = &id
+			}
+		}
+
+		for _, name := range strings.Split(get("roles"), userCSVRoleSep) {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			role, ok := lookup.roles[name]
+			switch {
+			case !ok:
+				fail("roles", "rol desconocido: "+name)
+			case role.IsSuperAdmin && !grantor.isSuper:
+				fail("roles", "solo un super admin puede asignar "+name)
+			case len(lookup.missing[name]) > 0:
+				fail("roles", "no puedes asignar "+name+": tiene permisos que no tienes ("+strings.Join(lookup.missing[name], ", ")+")")
+			case !hasRole(row.Roles, role.ID):
+				row.Roles = append(row.Roles, role)
+			}
+		}
+
+		if len(errs) > 0 {
+			rowErrs = append(rowErrs, errs...)
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, nil, errors.New("empty_file")
+	}
+
+	// Emails, usernames y RUTs ya usados (incluye usuarios borrados: siguen reservados)
+	for _, field := range []string{"email", "username", "rut"} {
+		values := make([]string, 0, len(rows))
+		for _, r := range rows {
+			if v := r.value(field); v != "" {
+				values = append(values, v)
+			}
+		}
+		taken, err := usedUserValues(h.DB, field, values)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range rows {
+			if v := r.value(field); v != "" && taken[v] {
+				rowErrs = append(rowErrs, userImportError{Row: r.Line, Field: field, Error: field + " ya registrado"})
+			}
+		}
+	}
+
+	return rows, rowErrs, nil
+}
+
+// detectCSVDelimiter usa ";" si el encabezado lo trae y no trae "," (Excel en español).
+func detectCSVDelimiter(data []byte) rune {
+	first, _ := bufio.NewReader(bytes.NewReader(data)).ReadString('\n')
+	if strings.Contains(first, ";") && !strings.Contains(first, ",") {
+		return ';'
+	}
+	return ','
+}
+
+// usedUserValues devuelve cuáles de los valores ya existen en users.<field> (incluye borrados).
+func usedUserValues(db *gorm.DB, field string, values []string) (map[string]bool, error) {
+	out := map[string]bool{}
+	if len(values) == 0 {
+		return out, nil
+	}
+
+	var used []string
+	if err := db.Unscoped().Model(&models.User{}).
+		Where(field+" IN ?", values).
+		Pluck(field, &used).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range used {
+		out[v] = true
+	}
+	return out, nil
+}
+
+// importLookup cachea roles y comunas para validar el archivo sin una consulta por fila.
+type importLookup struct {
+	validate *validator.Validate
+	roles    map[string]models.Role
+	missing  map[string][]string // rol -> permisos que quien importa no tiene
+	communes map[string][]uint // nombre en minúsculas -> ids
+	ids      map[uint]bool
+}
+
+func (h *AdminHandler) newImportLookup(grantor *roleGrantor) (*importLookup, error) {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil, errors.New("validator no disponible")
+	}
+
+	var roles []models.Role
+	if err := h.DB.Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	var communes []models.Commune
+	if err := h.DB.Select("id", "name").Find(&communes).Error; err != nil {
+		return nil, err
+	}
+
+	l := &importLookup{
+		validate: v,
+		roles:    map[string]models.Role{},
+		missing:  map[string][]string{},
+		communes: map[string][]uint{},
+		ids:      map[uint]bool{},
+	}
+	for _, r := range roles {
+		l.roles[r.Name] = r
+
+		missing, err := grantor.missing(h.DB, []models.Role{r})
+		if err != nil {
+			return nil, err
+		}
+		l.missing[r.Name] = missing
+	}
+	for _, co := range communes {
+		key := strings.ToLower(strings.TrimSpace(co.Name))
+		l.communes[key] = append(l.communes[key], co.ID)
+		l.ids[co.ID] = true
+	}
+	return l, nil
+}
+
+// commune resuelve una comuna por id o por nombre (sin distinguir mayúsculas).
+func (l *importLookup) commune(raw string) (uint, error) {
+	if n, err := strconv.ParseUint(raw, 10, 64); err == nil {
+		if !l.ids[uint(n)] {
+			return 0, errCommuneNotFound
+		}
+		return uint(n), nil
+	}
+
+	ids := l.communes[strings.ToLower(raw)]
+	switch len(ids) {
+	case 0:
+		return 0, errors.New("comuna no existe: " + raw)
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, errors.New("nombre de comuna ambiguo, usa el id: " + raw)
+	}
+}
@@ -2,29 +2,57 @@ package handlers
 
 import (
 	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"handsoft/internal/auth"
+	"handsoft/internal/mail"
 	"handsoft/internal/models"
+	"handsoft/internal/oidc"
+	"handsoft/internal/rut"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	DB        *gorm.DB
-	JWTConfig auth.JWTConfig
+	DB         *gorm.DB
+	JWTConfig  auth.JWTConfig
+	RefreshTTL time.Duration
+
+	Revocations *auth.RevocationStore
+
+	// Correos (reset de contraseña, etc.)
+	Mailer           mail.Sender
+	AppURL           string // URL del frontend para armar links
+	PasswordResetTTL time.Duration
+
+	EmailVerification EmailVerificationPolicy
+
+	// true = POST /register responde 403 (solo se crean cuentas por invitación)
+	DisablePublicRegistration bool
+
+	LoginProtection LoginProtection
+
+	PasswordPolicy auth.PasswordPolicy
+
+	// Hash de contraseñas nuevas; hashes más débiles se regeneran al iniciar sesión
+	Hasher auth.PasswordHasher
+
+	// Proveedores de SSO por nombre (vacío = SSO deshabilitado)
+	OIDC map[string]*oidc.Provider
 }
 
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Username string `json:"username" binding:"required,min=3,max=32"`
+	Username string `json:"username" binding:"required,min=3,max=32,excludes=@"` // sin @: el login busca por email o username
 	Password string `json:"password" binding:"required,min=8,max=72"`
 
 	FullName string `json:"full_name"`
 	Phone    string `json:"phone"`
+	RUT      string `json:"rut" binding:"omitempty,rut"` // opcional
 
 	// Dirección (obligatoria)
 	CommuneID    uint   `json:"commune_id" binding:"required"`
@@ -38,12 +66,32 @@ type RegisterRequest struct {
 	Extra                   string `json:"extra"`
 }
 
+func (r RegisterRequest) address() AddressInput {
+	return AddressInput{
+		CommuneID:              r.CommuneID,
+		Street:                 r.Street,
+		StreetNumber:           r.StreetNumber,
+		IsCondominium:          r.IsCondominium,
+		CondominiumHouseNumber: r.CondominiumHouseNumber,
+		BuildingNumber:         r.BuildingNumber,
+		ApartmentNumber:        r.ApartmentNumber,
+		Extra:                  r.Extra,
+	}
+}
+
 type LoginRequest struct {
 	Login    string `json:"login" binding:"required"` // email o username
 	Password string `json:"password" binding:"required"`
+	DeviceID string `json:"device_id"` // opcional, identifica el dispositivo del refresh token
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
+	// Con registro público deshabilitado solo se entra por invitación
+	if h.DisablePublicRegistration {
+		c.JSON(http.StatusForbidden, gin.H{"error": "registro público deshabilitado, solicita una invitación"})
+		return
+	}
+
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -56,93 +104,63 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	req.Phone = strings.TrimSpace(req.Phone)
 	req.FullName = strings.TrimSpace(req.FullName)
 
-	req.Street = strings.TrimSpace(req.Street)
-	req.StreetNumber = strings.TrimSpace(req.StreetNumber)
-	req.CondominiumHouseNumber = strings.TrimSpace(req.CondominiumHouseNumber)
-	req.BuildingNumber = strings.TrimSpace(req.BuildingNumber)
-	req.ApartmentNumber = strings.TrimSpace(req.ApartmentNumber)
-	req.Extra = strings.TrimSpace(req.Extra)
+	rutValue, err := normalizeRUT(req.RUT)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Chequear duplicados
+	// Chequear duplicados. Unscoped: un usuario borrado (lógico) conserva su email y username en los índices únicos
 	var count int64
-	h.DB.Model(&models.User{}).
+	if err := h.DB.Unscoped().Model(&models.User{}).
 		Where("email = ? OR username = ?", req.Email, req.Username).
-		Count(&count)
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el usuario"})
+		return
+	}
 	if count > 0 {
 		c.JSON(http.StatusConflict, gin.H{"error": "email o username ya existe"})
 		return
 	}
-
-	// Validar que la comuna exista (y por ende ciudad/región/país se infiere)
-	var commune models.Commune
-	if err := h.DB.First(&commune, req.CommuneID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "commune_id no existe"})
+	if rutValue != nil {
+		taken, err := userFieldTaken(h.DB, "rut", *rutValue, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el rut"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar la comuna"})
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": errRUTTaken.Error()})
+			return
+		}
+	}
+
+	if err := h.PasswordPolicy.Validate(req.Password, req.Username, req.Email); err != nil {
+		respondPasswordError(c, err)
 		return
 	}
 
-	hash, err := auth.HashPassword(req.Password)
+	hash, err := h.Hasher.Hash(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo procesar la contraseña"})
 		return
 	}
 
 	// Rol por defecto (user)
-	var role models.Role
-	if err := h.DB.Where("name = ?", "user").First(&role).Error; err != nil {
-		role = models.Role{Name: "user", Description: "Rol base"}
-		if err := h.DB.Create(&role).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo crear rol base"})
-			return
-		}
+	role, err := baseUserRole(h.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo crear rol base"})
+		return
 	}
 
 	// Reutilizar dirección si ya existe EXACTAMENTE igual
-	var addr models.Address
-	addrQuery := h.DB.Where(`
-		commune_id = ? AND
-		street = ? AND
-		street_number = ? AND
-		is_condominium = ? AND
-		COALESCE(condominium_house_number,'') = ? AND
-		COALESCE(building_number,'') = ? AND
-		COALESCE(apartment_number,'') = ? AND
-		COALESCE(extra,'') = ?
-	`,
-		req.CommuneID,
-		req.Street,
-		req.StreetNumber,
-		req.IsCondominium,
-		req.CondominiumHouseNumber,
-		req.BuildingNumber,
-		req.ApartmentNumber,
-		req.Extra,
-	)
-
-	if err := addrQuery.First(&addr).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// No existe -> crear
-			addr = models.Address{
-				CommuneID:               req.CommuneID,
-				Street:                  req.Street,
-				StreetNumber:            req.StreetNumber,
-				IsCondominium:           req.IsCondominium,
-				CondominiumHouseNumber:  req.CondominiumHouseNumber,
-				BuildingNumber:          req.BuildingNumber,
-				ApartmentNumber:         req.ApartmentNumber,
-				Extra:                   req.Extra,
-			}
-			if err := h.DB.Create(&addr).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo crear dirección"})
-				return
-			}
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo consultar dirección"})
+	addr, err := findOrCreateAddress(h.DB, req.address())
+	if err != nil {
+		if errors.Is(err, errCommuneNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo crear dirección"})
+		return
 	}
 
 	// Crear usuario con dirección
@@ -151,8 +169,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Username:     req.Username,
 		PasswordHash: hash,
 		IsActive:     true,
+		RUT:          rutValue,
 
 		AddressID: &addr.ID,
+		Addresses: []models.UserAddress{
+			{AddressID: addr.ID, Label: models.AddressLabelHome, IsDefault: true},
+		},
 
 		Contacts: models.Contact{
 			FullName: req.FullName,
@@ -177,12 +199,18 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// El registro no falla si el correo no sale: se puede pedir reenvío
+	if err := h.sendVerificationEmail(c.Request.Context(), u); err != nil {
+		log.Printf("register: no se pudo enviar verificación a user %d: %v", u.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"id":       u.ID,
 		"email":    u.Email,
 		"username": u.Username,
 		"created":  u.CreatedAt.Format(time.RFC3339),
 		"address_id": addr.ID,
+		"email_verified": false,
 	})
 }
 
@@ -195,39 +223,103 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	login := strings.ToLower(strings.TrimSpace(req.Login))
 
+	// Fuerza bruta por IP
+	if h.ipThrottled(c) {
+		return
+	}
+
 	var u models.User
 	err := h.DB.Preload("Roles").
 		Where("email = ? OR username = ?", login, req.Login).
 		First(&u).Error
 	if err != nil {
+		recordLoginAttempt(h.DB, c, login, nil, false, "unknown_user")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "credenciales inválidas"})
 		return
 	}
 
+	// Fuerza bruta por cuenta (bloqueo temporal / delay progresivo)
+	if h.accountThrottled(c, login, u) {
+		return
+	}
+
 	if !u.IsActive {
+		recordLoginAttempt(h.DB, c, login, &u.ID, false, "inactive")
 		c.JSON(http.StatusForbidden, gin.H{"error": "usuario desactivado"})
 		return
 	}
 
-	if !auth.CheckPassword(u.PasswordHash, req.Password) {
+	ok, needsRehash := h.Hasher.Verify(u.PasswordHash, req.Password)
+	if !ok {
+		h.registerFailure(c, login, u, "bad_password")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "credenciales inválidas"})
 		return
 	}
+	if needsRehash {
+		h.rehashPassword(u, req.Password)
+	}
 
-	roles := make([]string, 0, len(u.Roles))
-	for _, r := range u.Roles {
-		roles = append(roles, r.Name)
+	if !h.EmailVerification.allowsLogin(u) {
+		recordLoginAttempt(h.DB, c, login, &u.ID, false, "email_unverified")
+		c.JSON(http.StatusForbidden, gin.H{"error": "email no verificado"})
+		return
+	}
+
+	// Segundo factor: si aplica, se responde con un mfa_token en vez de access token.
+	// Los contadores de fallos se limpian recién cuando se completa el segundo factor.
+	if h.mfaChallenge(c, u) {
+		recordLoginAttempt(h.DB, c, login, &u.ID, true, "mfa_pending")
+		return
 	}
 
-	token, err := auth.SignAccessToken(h.JWTConfig, u.ID, roles)
+	h.registerSuccess(c, login, u)
+
+	resp, _, err := h.issueTokens(h.DB, c, u, "", strings.TrimSpace(req.DeviceID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo generar token"})
 		return
 	}
+	resp["email_verified"] = u.EmailVerifiedAt != nil
 
-	c.JSON(http.StatusOK, gin.H{
-		"access_token": token,
-		"token_type":   "Bearer",
-		"expires_in":   int(h.JWTConfig.AccessTTL.Seconds()),
-	})
+	c.JSON(http.StatusOK, resp)
+}
+
+// rehashPassword reemplaza un hash con algoritmo o parámetros más débiles que los configurados.
+// Solo actualiza si el hash no cambió entretanto; los errores solo se loguean (el login sigue).
+func (h *AuthHandler) rehashPassword(u models.User, plain string) {
+	hash, err := h.Hasher.Hash(plain)
+	if err != nil {
+		log.Printf("login: no se pudo regenerar hash de user %d: %v", u.ID, err)
+		return
+	}
+
+	if err := h.DB.Model(&models.User{}).
+		Where("id = ? AND password_hash = ?", u.ID, u.PasswordHash).
+		UpdateColumn("password_hash", hash).Error; err != nil {
+		log.Printf("login: no se pudo guardar hash regenerado de user %d: %v", u.ID, err)
+	}
+}
+
+// baseUserRole devuelve el rol base "user", creándolo si aún no existe.
+func baseUserRole(db *gorm.DB) (models.Role, error) {
+	var role models.Role
+	if err := db.Where("name = ?", "user").First(&role).Error; err != nil {
+		role = models.Role{Name: "user", Description: "Rol base"}
+		if err := db.Create(&role).Error; err != nil {
+			return role, err
+		}
+	}
+	return role, nil
+}
+
+// normalizeRUT valida y normaliza un RUT opcional ("" => nil).
+func normalizeRUT(raw string) (*string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	value, err := rut.Normalize(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
 }
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Token implementa el grant client_credentials de OAuth2 (RFC 6749 §4.4) para service accounts.
+// Las credenciales van por HTTP Basic (client_secret_basic) o en el form (client_secret_post).
+// Los errores siguen el formato OAuth2 ({"error": "invalid_client"}) para que funcionen las librerías estándar.
+func (h *AuthHandler) Token(c *gin.Context) {
+	// Los tokens nunca se cachean (RFC 6749 §5.1)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	if c.PostForm("grant_type") != "client_credentials" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+		return
+	}
+
+	clientID, secret, basic := c.Request.BasicAuth()
+	if !basic {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+	clientID = strings.TrimSpace(clientID)
+	if clientID == "" || secret == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return
+	}
+
+	// Fuerza bruta por IP (comparte el historial con el login de usuarios)
+	if h.ipThrottled(c) {
+		return
+	}
+
+	login := "sa:" + clientID
+
+	var sa models.ServiceAccount
+	res := h.DB.Preload("Roles").Where("client_id = ?", clientID).Limit(1).Find(&sa)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	// Se compara igual aunque no exista, para no filtrar client_ids válidos por tiempo de respuesta
+	ok := subtle.ConstantTimeCompare([]byte(auth.HashOpaqueToken(secret)), []byte(sa.SecretHash)) == 1
+	if res.RowsAffected == 0 || !ok {
+		recordLoginAttempt(h.DB, c, login, nil, false, "bad_client_secret")
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="token"`)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return
+	}
+
+	if !sa.IsActive {
+		recordLoginAttempt(h.DB, c, login, nil, false, "inactive")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": "service account desactivada"})
+		return
+	}
+
+	roles := make([]string, 0, len(sa.Roles))
+	for _, r := range sa.Roles {
+		roles = append(roles, r.Name)
+	}
+
+	issuedAt, err := h.Revocations.ServiceIssueTime(sa.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	token, err := auth.SignServiceToken(h.JWTConfig, sa.ID, sa.ClientID, roles, issuedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	recordLoginAttempt(h.DB, c, login, nil, true, "client_credentials")
+	if err := h.DB.Model(&sa).UpdateColumn("last_used_at", time.Now()).Error; err != nil {
+		log.Printf("token: no se pudo actualizar last_used_at de service account %d: %v", sa.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(h.JWTConfig.AccessTTL.Seconds()),
+	})
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type InspectInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username" binding:"required,min=3,max=32,excludes=@"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+
+	FullName string `json:"full_name"`
+	Phone    string `json:"phone"`
+	RUT      string `json:"rut" binding:"omitempty,rut"`
+}
+
+var errInvitationInvalid = errors.New("invitación inválida o expirada")
+
+// pendingInvitation busca la invitación por token (hash) y exige que siga pendiente.
+func pendingInvitation(db *gorm.DB, plain string) (*models.Invitation, error) {
+	var inv models.Invitation
+	err := db.Preload("Roles").
+		Where("token_hash = ?", auth.HashOpaqueToken(strings.TrimSpace(plain))).
+		First(&inv).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvitationInvalid
+		}
+		return nil, err
+	}
+	if !inv.Pending(time.Now()) {
+		return nil, errInvitationInvalid
+	}
+	return &inv, nil
+}
+
+// InspectInvitation devuelve los datos visibles de una invitación pendiente (para el formulario de alta).
+func (h *AuthHandler) InspectInvitation(c *gin.Context) {
+	var req InspectInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inv, err := pendingInvitation(h.DB, req.Token)
+	if err != nil {
+		if errors.Is(err, errInvitationInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar la invitación"})
+		return
+	}
+
+	roles := make([]string, 0, len(inv.Roles))
+	for _, r := range inv.Roles {
+		roles = append(roles, r.Name)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":      inv.Email,
+		"roles":      roles,
+		"expires_at": inv.ExpiresAt,
+	})
+}
+
+// AcceptInvitation crea la cuenta con el email y los roles de la invitación.
+// El email queda verificado (el link llegó a ese correo).
+func (h *AuthHandler) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	req.FullName = strings.TrimSpace(req.FullName)
+	req.Phone = strings.TrimSpace(req.Phone)
+
+	inv, err := pendingInvitation(h.DB, req.Token)
+	if err != nil {
+		if errors.Is(err, errInvitationInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar la invitación"})
+		return
+	}
+
+	rutValue, err := normalizeRUT(req.RUT)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Unscoped: un usuario borrado (lógico) conserva su email y username en los índices únicos
+	var count int64
+	if err := h.DB.Unscoped().Model(&models.User{}).
+		Where("email = ? OR username = ?", inv.Email, req.Username).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el usuario"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "email o username ya existe"})
+		return
+	}
+	if rutValue != nil {
+		taken, err := userFieldTaken(h.DB, "rut", *rutValue, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el rut"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": errRUTTaken.Error()})
+			return
+		}
+	}
+
+	if err := h.PasswordPolicy.Validate(req.Password, req.Username, inv.Email); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	hash, err := h.Hasher.Hash(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo procesar la contraseña"})
+		return
+	}
+
+	now := time.Now()
+	u := models.User{
+		Email:           inv.Email,
+		Username:        req.Username,
+		PasswordHash:    hash,
+		IsActive:        true,
+		RUT:             rutValue,
+		EmailVerifiedAt: &now,
+
+		Contacts: models.Contact{
+			FullName: req.FullName,
+		},
+
+		Roles: inv.Roles,
+	}
+	if req.Phone != "" {
+		u.Phones = []models.UserPhone{
+			{
+				Label:  "mobile",
+				Number: req.Phone,
+				IsMain: true,
+			},
+		}
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// Un solo uso: si otra request la aceptó entretanto, no se crea nada
+		res := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", inv.ID).
+			Update("accepted_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvitationInvalid
+		}
+
+		if err := tx.Create(&u).Error; err != nil {
+			return err
+		}
+
+		if inv.SpaceID != nil || inv.WarehouseID != nil {
+			scope := models.UserScope{UserID: u.ID, SpaceID: inv.SpaceID, WarehouseID: inv.WarehouseID}
+			if err := tx.Create(&scope).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.Invitation{}).
+			Where("id = ?", inv.ID).
+			Update("accepted_user_id", u.ID).Error
+	})
+	if err != nil {
+		if errors.Is(err, errInvitationInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo crear usuario"})
+		return
+	}
+
+	roles := make([]string, 0, len(u.Roles))
+	for _, r := range u.Roles {
+		roles = append(roles, r.Name)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":             u.ID,
+		"email":          u.Email,
+		"username":       u.Username,
+		"created":        u.CreatedAt.Format(time.RFC3339),
+		"roles":          roles,
+		"email_verified": true,
+	})
+}
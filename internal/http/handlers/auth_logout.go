@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/http/middleware"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LogoutRequest struct {
+	// Opcional: si viene, se revoca también la familia de ese refresh token
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Logout revoca el access token actual (por jti), su sesión y, si se envía, la familia del refresh token.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims, ok := claimsFromCtx(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autenticado"})
+		return
+	}
+
+	var req LogoutRequest
+	// El body es opcional
+	_ = c.ShouldBindJSON(&req)
+
+	if err := h.Revocations.RevokeToken(claims, "logout"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo cerrar sesión"})
+		return
+	}
+
+	// Sesión del access token (tokens emitidos con sid)
+	if claims.SessionID != 0 {
+		var sess models.UserSession
+		if err := h.DB.Where("id = ? AND user_id = ?", claims.SessionID, claims.UserID).First(&sess).Error; err == nil {
+			if err := h.Revocations.RevokeFamily(sess.FamilyID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo cerrar sesión"})
+				return
+			}
+		}
+	}
+
+	if rt := strings.TrimSpace(req.RefreshToken); rt != "" {
+		var current models.RefreshToken
+		err := h.DB.Where("token_hash = ? AND user_id = ?", auth.HashOpaqueToken(rt), claims.UserID).
+			First(&current).Error
+		if err == nil {
+			if err := h.Revocations.RevokeFamily(current.FamilyID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo cerrar sesión"})
+				return
+			}
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll revoca todos los access y refresh tokens del usuario actual.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	claims, ok := claimsFromCtx(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autenticado"})
+		return
+	}
+
+	if err := h.Revocations.RevokeUser(claims.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo cerrar sesión"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func claimsFromCtx(c *gin.Context) (*auth.Claims, bool) {
+	v, ok := c.Get(middleware.CtxClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*auth.Claims)
+	return claims, ok
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoginMFARequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	DeviceID     string `json:"device_id"`
+}
+
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type MFAEnrollConfirmRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	DeviceID string `json:"device_id"`
+}
+
+// mfaChallenge responde el login sin access token cuando falta el segundo factor.
+// Devuelve false si no aplica MFA y el login puede seguir normalmente.
+func (h *AuthHandler) mfaChallenge(c *gin.Context, u models.User) bool {
+	purpose := ""
+	switch {
+	case u.TOTPEnabledAt != nil:
+		purpose = auth.PurposeMFALogin
+	case roleRequiresMFA(u):
+		purpose = auth.PurposeMFAEnroll
+	default:
+		return false
+	}
+
+	token, err := auth.SignPurposeToken(h.JWTConfig, u.ID, purpose, mfaChallengeTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo generar token"})
+		return true
+	}
+
+	resp := gin.H{
+		"mfa_token":  token,
+		"expires_in": int(mfaChallengeTTL.Seconds()),
+	}
+	if purpose == auth.PurposeMFALogin {
+		resp["mfa_required"] = true
+	} else {
+		resp["mfa_enrollment_required"] = true
+	}
+
+	c.JSON(http.StatusOK, resp)
+	return true
+}
+
+// mfaUser valida el token intermedio y carga al usuario (activo) con roles.
+func (h *AuthHandler) mfaUser(c *gin.Context, token, purpose string) (*auth.Claims, *models.User, bool) {
+	claims, err := auth.VerifyPurposeToken(h.JWTConfig, strings.TrimSpace(token), purpose)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "mfa_token inválido o expirado"})
+		return nil, nil, false
+	}
+
+	revoked, err := h.Revocations.IsRevoked(claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el token"})
+		return nil, nil, false
+	}
+	if revoked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "mfa_token inválido o expirado"})
+		return nil, nil, false
+	}
+
+	var u models.User
+	if err := h.DB.Preload("Roles").First(&u, claims.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "mfa_token inválido o expirado"})
+		return nil, nil, false
+	}
+	if !u.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "usuario desactivado"})
+		return nil, nil, false
+	}
+
+	return claims, &u, true
+}
+
+// finishMFALogin invalida el token intermedio (un solo uso) y entrega access + refresh token.
+// extra se agrega a la respuesta (ej: códigos de recuperación recién generados).
+func (h *AuthHandler) finishMFALogin(c *gin.Context, claims *auth.Claims, u models.User, deviceID string, extra gin.H) {
+	// Sin invalidar el mfa_token no se entregan tokens: quedaría reutilizable hasta que expire
+	if err := h.Revocations.RevokeToken(claims, "mfa_used"); err != nil {
+		log.Printf("mfa: no se pudo invalidar mfa_token de user %d: %v", u.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo completar el login"})
+		return
+	}
+
+	h.registerSuccess(c, u.Email, u)
+
+	resp, _, err := h.issueTokens(h.DB, c, u, "", strings.TrimSpace(deviceID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo generar token"})
+		return
+	}
+	resp["email_verified"] = u.EmailVerifiedAt != nil
+	for k, v := range extra {
+		resp[k] = v
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// LoginMFA completa el login con un código TOTP o un código de recuperación.
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" && strings.TrimSpace(req.RecoveryCode) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code o recovery_code es requerido"})
+		return
+	}
+
+	claims, u, ok := h.mfaUser(c, req.MFAToken, auth.PurposeMFALogin)
+	if !ok {
+		return
+	}
+
+	// Los códigos fallidos cuentan igual que contraseñas fallidas
+	if h.accountThrottled(c, u.Email, *u) {
+		return
+	}
+
+	if err := verifySecondFactor(h.DB, *u, req.Code, req.RecoveryCode); err != nil {
+		if errors.Is(err, errMFAInvalidCode) || errors.Is(err, errMFANotEnrolled) {
+			h.registerFailure(c, u.Email, *u, "mfa_invalid")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errMFAInvalidCode.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el código"})
+		return
+	}
+
+	h.finishMFALogin(c, claims, *u, req.DeviceID, nil)
+}
+
+// MFAEnroll inicia el enrolamiento TOTP para un usuario cuyo rol exige MFA (durante el login).
+func (h *AuthHandler) MFAEnroll(c *gin.Context) {
+	var req MFAEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, u, ok := h.mfaUser(c, req.MFAToken, auth.PurposeMFAEnroll)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := startTOTPEnrollment(h.DB, *u)
+	if err != nil {
+		if errors.Is(err, errMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo iniciar el enrolamiento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// MFAEnrollConfirm confirma el enrolamiento con el primer código y completa el login.
+func (h *AuthHandler) MFAEnrollConfirm(c *gin.Context) {
+	var req MFAEnrollConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, u, ok := h.mfaUser(c, req.MFAToken, auth.PurposeMFAEnroll)
+	if !ok {
+		return
+	}
+
+	codes, err := confirmTOTPEnrollment(h.DB, *u, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, errMFAInvalidCode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, errMFAAlreadyEnabled), errors.Is(err, errMFANotEnrolled):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo confirmar el enrolamiento"})
+		}
+		return
+	}
+
+	h.finishMFALogin(c, claims, *u, req.DeviceID, gin.H{"recovery_codes": codes})
+}
@@ -0,0 +1,605 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"handsoft/internal/http/middleware"
+	"handsoft/internal/models"
+	"handsoft/internal/oidc"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Tiempo que tiene el usuario para volver del IdP con el code
+const oidcStateTTL = 10 * time.Minute
+
+type OIDCCallbackRequest struct {
+	Code     string `json:"code" binding:"required"`
+	State    string `json:"state" binding:"required"`
+	DeviceID string `json:"device_id"`
+}
+
+var errOIDCState = errors.New("state inválido o expirado")
+
+func (h *AuthHandler) oidcProvider(c *gin.Context) (*oidc.Provider, bool) {
+	p, ok := h.OIDC[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proveedor no configurado"})
+		return nil, false
+	}
+	return p, true
+}
+
+// OIDCProviders lista los proveedores disponibles (para mostrar botones en el login).
+func (h *AuthHandler) OIDCProviders(c *gin.Context) {
+	names := make([]string, 0, len(h.OIDC))
+	for name := range h.OIDC {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+// OIDCAuthorize genera state, nonce y code_verifier y devuelve la URL del IdP.
+// El frontend redirige ahí y luego manda code + state al callback.
+func (h *AuthHandler) OIDCAuthorize(c *gin.Context) {
+	p, ok := h.oidcProvider(c)
+	if !ok {
+		return
+	}
+	h.startOIDC(c, p, nil)
+}
+
+// OIDCLinkAuthorize es el authorize para vincular una cuenta del IdP al usuario de la sesión.
+// El frontend manda code + state a POST /oidc/:provider/link.
+func (h *AuthHandler) OIDCLinkAuthorize(c *gin.Context) {
+	p, ok := h.oidcProvider(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint(middleware.CtxUserIDKey)
+	h.startOIDC(c, p, &userID)
+}
+
+// startOIDC guarda el state (con el usuario a vincular, si aplica) y responde la URL del IdP.
+func (h *AuthHandler) startOIDC(c *gin.Context, p *oidc.Provider, linkUserID *uint) {
+	var values [3]string
+	for i := range values {
+		v, err := oidc.RandomString()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo iniciar el login"})
+			return
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	now := time.Now()
+	st := models.OIDCLoginState{
+		State:        state,
+		Provider:     p.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(oidcStateTTL),
+		LinkUserID:   linkUserID,
+	}
+	if err := h.DB.Create(&st).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo iniciar el login"})
+		return
+	}
+
+	// Limpieza de states abandonados (errores solo se loguean)
+	if err := h.DB.Where("expires_at < ?", now).Delete(&models.OIDCLoginState{}).Error; err != nil {
+		log.Printf("oidc: no se pudo limpiar states expirados: %v", err)
+	}
+
+	authURL, err := p.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("oidc %s: %v", p.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "proveedor no disponible"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_url": authURL,
+		"state":             state,
+		"expires_in":        int(oidcStateTTL.Seconds()),
+	})
+}
+
+// OIDCCallback canjea el code, valida el ID token, resuelve el usuario local y entrega nuestros tokens.
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	p, ok := h.oidcProvider(c)
+	if !ok {
+		return
+	}
+
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Fuerza bruta por IP, igual que el login con contraseña
+	if h.ipThrottled(c) {
+		return
+	}
+
+	st, err := h.consumeOIDCState(p.Name, strings.TrimSpace(req.State), nil)
+	if err != nil {
+		if errors.Is(err, errOIDCState) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el state"})
+		return
+	}
+
+	id, err := p.Exchange(c.Request.Context(), strings.TrimSpace(req.Code), st.CodeVerifier, st.Nonce)
+	if err != nil {
+		log.Printf("oidc %s: %v", p.Name, err)
+		recordLoginAttempt(h.DB, c, "oidc:"+p.Name, nil, false, "oidc_invalid")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no se pudo validar la identidad"})
+		return
+	}
+
+	var u models.User
+	rolesChanged := false
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		user, changed, err := h.oidcUser(tx, p, id)
+		if err != nil {
+			return err
+		}
+		u = *user
+		rolesChanged = changed
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errOIDCNoEmail) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, errOIDCNotInvited) {
+			recordLoginAttempt(h.DB, c, strings.ToLower(id.Email), nil, false, "oidc_not_invited")
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, errOIDCLinkRequired) {
+			recordLoginAttempt(h.DB, c, strings.ToLower(id.Email), nil, false, "oidc_link_required")
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "link_required": true})
+			return
+		}
+		if errors.Is(err, errOIDCUserGone) {
+			recordLoginAttempt(h.DB, c, strings.ToLower(id.Email), nil, false, "unknown_user")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "credenciales inválidas"})
+			return
+		}
+		log.Printf("oidc %s: sub %s: %v", p.Name, id.Subject, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo resolver el usuario"})
+		return
+	}
+
+	// Los grupos del IdP cambiaron los roles: los access tokens vigentes llevan los roles anteriores
+	if rolesChanged {
+		if err := h.Revocations.InvalidateAccessTokens(u.ID); err != nil {
+			log.Printf("oidc %s: no se pudieron invalidar tokens de user %d: %v", p.Name, u.ID, err)
+		}
+	}
+
+	login := strings.ToLower(u.Email)
+
+	// Mismas reglas que el login con contraseña: bloqueo de cuenta, activo, email verificado
+	if h.accountThrottled(c, login, u) {
+		return
+	}
+
+	if !u.IsActive {
+		recordLoginAttempt(h.DB, c, login, &u.ID, false, "inactive")
+		c.JSON(http.StatusForbidden, gin.H{"error": "usuario desactivado"})
+		return
+	}
+
+	if !h.EmailVerification.allowsLogin(u) {
+		recordLoginAttempt(h.DB, c, login, &u.ID, false, "email_unverified")
+		c.JSON(http.StatusForbidden, gin.H{"error": "email no verificado"})
+		return
+	}
+
+	// El IdP reemplaza la contraseña, no el segundo factor local (TOTP o rol que exige MFA)
+	if h.mfaChallenge(c, u) {
+		recordLoginAttempt(h.DB, c, login, &u.ID, true, "mfa_pending")
+		return
+	}
+
+	recordLoginAttempt(h.DB, c, login, &u.ID, true, "oidc:"+p.Name)
+
+	resp, _, err := h.issueTokens(h.DB, c, u, "", strings.TrimSpace(req.DeviceID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo generar token"})
+		return
+	}
+	resp["email_verified"] = u.EmailVerifiedAt != nil
+	resp["provider"] = p.Name
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// OIDCLinkCallback vincula la cuenta del IdP al usuario de la sesión (el que inició el authorize).
+func (h *AuthHandler) OIDCLinkCallback(c *gin.Context) {
+	p, ok := h.oidcProvider(c)
+	if !ok {
+		return
+	}
+
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint(middleware.CtxUserIDKey)
+	st, err := h.consumeOIDCState(p.Name, strings.TrimSpace(req.State), &userID)
+	if err != nil {
+		if errors.Is(err, errOIDCState) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el state"})
+		return
+	}
+
+	id, err := p.Exchange(c.Request.Context(), strings.TrimSpace(req.Code), st.CodeVerifier, st.Nonce)
+	if err != nil {
+		log.Printf("oidc %s: %v", p.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no se pudo validar la identidad"})
+		return
+	}
+
+	now := time.Now()
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var ident models.UserIdentity
+		res := tx.Where("provider = ? AND subject = ?", p.Name, id.Subject).Limit(1).Find(&ident)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 && ident.UserID != userID {
+			return errOIDCLinkedOther
+		}
+
+		ident.UserID = userID
+		ident.Provider = p.Name
+		ident.Subject = id.Subject
+		ident.Email = strings.ToLower(strings.TrimSpace(id.Email))
+		ident.LastLoginAt = &now
+		return tx.Save(&ident).Error
+	})
+	if err != nil {
+		if errors.Is(err, errOIDCLinkedOther) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("oidc %s: vincular sub %s a user %d: %v", p.Name, id.Subject, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo vincular la cuenta"})
+		return
+	}
+
+	log.Printf("oidc %s: sub %s vinculado a user %d", p.Name, id.Subject, userID)
+	c.JSON(http.StatusOK, gin.H{"provider": p.Name, "linked": true})
+}
+
+// consumeOIDCState borra el state (un solo uso) y lo devuelve si sigue vigente.
+// linkUserID debe calzar con el del authorize: un state de vinculación no sirve para login ni al revés.
+func (h *AuthHandler) consumeOIDCState(provider, state string, linkUserID *uint) (*models.OIDCLoginState, error) {
+	q := h.DB.Where("state = ? AND provider = ?", state, provider)
+	if linkUserID == nil {
+		q = q.Where("link_user_id IS NULL")
+	} else {
+		q = q.Where("link_user_id = ?", *linkUserID)
+	}
+
+	var st models.OIDCLoginState
+	res := q.Limit(1).Find(&st)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errOIDCState
+	}
+
+	del := h.DB.Where("id = ?", st.ID).Delete(&models.OIDCLoginState{})
+	if del.Error != nil {
+		return nil, del.Error
+	}
+	if del.RowsAffected == 0 || time.Now().After(st.ExpiresAt) {
+		return nil, errOIDCState
+	}
+
+	return &st, nil
+}
+
+var (
+	errOIDCNoEmail      = errors.New("el proveedor no informó un email")
+	errOIDCLinkRequired = errors.New("ya existe una cuenta con ese email: inicia sesión y vincula el proveedor desde tu cuenta")
+	errOIDCLinkedOther  = errors.New("esa cuenta del proveedor ya está vinculada a otro usuario")
+	errOIDCNotInvited   = errors.New("el registro está cerrado: necesitas una invitación")
+	errOIDCUserGone     = errors.New("la cuenta vinculada ya no existe")
+)
+
+// oidcUser resuelve el usuario local: identidad ya vinculada, usuario existente con el mismo
+// email (solo si el proveedor es de confianza para vincular) o, si no hay ninguno, uno nuevo.
+// Luego sincroniza los roles mapeados.
+func (h *AuthHandler) oidcUser(tx *gorm.DB, p *oidc.Provider, id *oidc.Identity) (*models.User, bool, error) {
+	now := time.Now()
+	email := strings.ToLower(strings.TrimSpace(id.Email))
+
+	var ident models.UserIdentity
+	res := tx.Where("provider = ? AND subject = ?", p.Name, id.Subject).Limit(1).Find(&ident)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+
+	var u models.User
+	isNew := false
+
+	if res.RowsAffected > 0 {
+		// La identidad sigue apuntando a un usuario borrado (lógico): no entra ni se re-vincula
+		err := tx.Preload("Roles").First(&u, ident.UserID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, errOIDCUserGone
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		if email == "" {
+			return nil, false, errOIDCNoEmail
+		}
+
+		r := tx.Preload("Roles").Where("email = ?", email).Limit(1).Find(&u)
+		if r.Error != nil {
+			return nil, false, r.Error
+		}
+
+		// Cuenta local con el mismo email: entrar con ella es tomarla (roles, MFA, datos).
+		// Solo se vincula sola si el IdP es de confianza y declara el email verificado
+		if r.RowsAffected > 0 && (!p.TrustEmailLinking || !id.EmailVerified) {
+			return nil, false, errOIDCLinkRequired
+		}
+
+		if r.RowsAffected == 0 {
+			created, err := h.provisionOIDCUser(tx, p, id, email)
+			if err != nil {
+				return nil, false, err
+			}
+			u = *created
+			isNew = true
+		}
+
+		ident = models.UserIdentity{
+			UserID:   u.ID,
+			Provider: p.Name,
+			Subject:  id.Subject,
+		}
+	}
+
+	ident.Email = email
+	ident.LastLoginAt = &now
+	if err := tx.Save(&ident).Error; err != nil {
+		return nil, false, err
+	}
+
+	// Un email verificado por el IdP cuenta como verificado localmente
+	if id.EmailVerified && u.EmailVerifiedAt == nil && strings.EqualFold(u.Email, email) {
+		if err := tx.Model(&u).Update("email_verified_at", now).Error; err != nil {
+			return nil, false, err
+		}
+		u.EmailVerifiedAt = &now
+	}
+
+	changed, err := syncOIDCRoles(tx, p, &u, id.Groups, isNew)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Un usuario recién creado no tiene tokens que invalidar
+	return &u, changed && !isNew, nil
+}
+
+var usernameCleaner = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// provisionOIDCUser crea el usuario con una contraseña aleatoria inutilizable (solo entra por SSO
+// hasta que pida un reset). Con el registro público cerrado solo se crea si hay una invitación
+// pendiente para el email, que queda aceptada (roles y alcance de la invitación).
+func (h *AuthHandler) provisionOIDCUser(tx *gorm.DB, p *oidc.Provider, id *oidc.Identity, email string) (*models.User, error) {
+	var inv *models.Invitation
+	if h.DisablePublicRegistration {
+		found, err := pendingInvitationForEmail(tx, email)
+		if err != nil {
+			return nil, err
+		}
+		// Sin email verificado el IdP no prueba que la invitación sea de esta persona
+		if found == nil || !id.EmailVerified {
+			return nil, errOIDCNotInvited
+		}
+		inv = found
+	}
+
+	random, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := h.Hasher.Hash(random)
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := uniqueUsername(tx, id.PreferredUsername, email)
+	if err != nil {
+		return nil, err
+	}
+
+	u := models.User{
+		Email:        email,
+		Username:     username,
+		PasswordHash: hash,
+		IsActive:     true,
+
+		Contacts: models.Contact{
+			FullName: strings.TrimSpace(id.Name),
+		},
+	}
+	if id.EmailVerified {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+
+	if inv != nil {
+		u.Roles = inv.Roles
+	}
+
+	if err := tx.Create(&u).Error; err != nil {
+		return nil, err
+	}
+
+	if inv != nil {
+		if err := acceptInvitationFor(tx, inv, u.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("oidc %s: usuario %d creado para sub %s", p.Name, u.ID, id.Subject)
+	return &u, nil
+}
+
+// pendingInvitationForEmail devuelve la invitación pendiente más reciente para el email (nil si no hay).
+func pendingInvitationForEmail(tx *gorm.DB, email string) (*models.Invitation, error) {
+	var inv models.Invitation
+	res := tx.Preload("Roles").
+		Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", email, time.Now()).
+		Order("created_at desc").
+		Limit(1).
+		Find(&inv)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &inv, nil
+}
+
+// acceptInvitationFor marca la invitación como aceptada por el usuario y copia su alcance.
+func acceptInvitationFor(tx *gorm.DB, inv *models.Invitation, userID uint) error {
+	res := tx.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", inv.ID).
+		Updates(map[string]any{"accepted_at": time.Now(), "accepted_user_id": userID})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errOIDCNotInvited
+	}
+
+	if inv.SpaceID != nil || inv.WarehouseID != nil {
+		scope := models.UserScope{UserID: userID, SpaceID: inv.SpaceID, WarehouseID: inv.WarehouseID}
+		return tx.Create(&scope).Error
+	}
+	return nil
+}
+
+// uniqueUsername deriva un username válido (3-32) del preferred_username o del email.
+func uniqueUsername(tx *gorm.DB, preferred, email string) (string, error) {
+	base := preferred
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = usernameCleaner.ReplaceAllString(base, "")
+	if len(base) > 28 {
+		base = base[:28]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	candidate := base
+	for i := 2; i < 1000; i++ {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", errors.New("no se pudo generar un username único")
+}
+
+// syncOIDCRoles agrega/quita solo los roles que controla el mapeo de grupos; los asignados a mano
+// se respetan. Un usuario nuevo sin grupos mapeados ni roles de invitación recibe el rol por defecto.
+// Indica si cambió el conjunto de roles.
+func syncOIDCRoles(tx *gorm.DB, p *oidc.Provider, u *models.User, groups []string, isNew bool) (bool, error) {
+	wanted := p.MappedRoles(groups)
+	if isNew && len(wanted) == 0 && len(u.Roles) == 0 && p.DefaultRole != "" {
+		wanted = []string{p.DefaultRole}
+	}
+
+	managed := p.ManagedRoles()
+	want := map[string]bool{}
+	for _, name := range wanted {
+		want[name] = true
+	}
+
+	have := map[string]bool{}
+	var remove []models.Role
+	for _, r := range u.Roles {
+		have[r.Name] = true
+		if managed[r.Name] && !want[r.Name] {
+			remove = append(remove, r)
+		}
+	}
+
+	var add []models.Role
+	for _, name := range wanted {
+		if have[name] {
+			continue
+		}
+		var role models.Role
+		res := tx.Where("name = ?", name).Limit(1).Find(&role)
+		if res.Error != nil {
+			return false, res.Error
+		}
+		if res.RowsAffected == 0 {
+			log.Printf("oidc %s: rol %q mapeado no existe", p.Name, name)
+			continue
+		}
+		add = append(add, role)
+	}
+
+	if len(remove) == 0 && len(add) == 0 {
+		return false, nil
+	}
+
+	assoc := tx.Model(u).Association("Roles")
+	if len(remove) > 0 {
+		if err := assoc.Delete(remove); err != nil {
+			return false, err
+		}
+	}
+	if len(add) > 0 {
+		if err := assoc.Append(add); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Preload("Roles").First(u, u.ID).Error
+}
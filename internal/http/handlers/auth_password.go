@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"handsoft/internal/mail"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
+
+// ForgotPassword envía un link de reset si el email existe.
+// Siempre responde lo mismo para no revelar qué emails están registrados.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	accepted := gin.H{"message": "si el email existe, enviaremos instrucciones para restablecer la contraseña"}
+
+	var u models.User
+	if err := h.DB.Where("email = ?", email).First(&u).Error; err != nil || !u.IsActive {
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+
+	token, err := createUserToken(h.DB, u.ID, models.TokenPurposePasswordReset, h.PasswordResetTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo generar el token"})
+		return
+	}
+
+	msg := passwordResetMessage(h.AppURL, h.PasswordResetTTL, u, token,
+		"Si no lo solicitaste, ignora este correo.")
+	if err := h.Mailer.Send(c.Request.Context(), msg); err != nil {
+		// No se expone al cliente para no revelar si el email existe
+		log.Printf("forgot password: no se pudo enviar correo a user %d: %v", u.ID, err)
+	}
+
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+// ResetPassword cambia la contraseña usando un token de reset (un solo uso)
+// y cierra todas las sesiones del usuario.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var userID uint
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		t, err := consumeUserToken(tx, strings.TrimSpace(req.Token), models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = t.UserID
+
+		var u models.User
+		if err := tx.First(&u, t.UserID).Error; err != nil {
+			return err
+		}
+
+		return setPassword(tx, h.Hasher, h.PasswordPolicy, u, req.Password)
+	})
+	if err != nil {
+		if errors.Is(err, errUserTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if respondPasswordError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo restablecer la contraseña"})
+		return
+	}
+
+	if err := h.Revocations.RevokeUser(userID); err != nil {
+		log.Printf("reset password: no se pudieron revocar sesiones de user %d: %v", userID, err)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// passwordResetMessage arma el correo con el link de reset (note va al final del cuerpo).
+func passwordResetMessage(appURL string, ttl time.Duration, u models.User, token, note string) mail.Message {
+	link := appURL + "/reset-password?token=" + url.QueryEscape(token)
+	return mail.Message{
+		To:      []string{u.Email},
+		Subject: "Restablecer contraseña",
+		Text: fmt.Sprintf(
+			"Hola %s,\n\nPara restablecer tu contraseña abre el siguiente link (válido por %d minutos):\n\n%s\n\n%s\n",
+			u.Username, int(ttl.Minutes()), link, note,
+		),
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+var errRefreshReused = errors.New("refresh token reutilizado")
+
+// issueTokens firma un access token y crea un refresh token dentro de la familia indicada.
+// Si familyID viene vacío, se abre una familia nueva (login). Cada familia es una sesión
+// (models.UserSession), que se crea o actualiza acá.
+func (h *AuthHandler) issueTokens(tx *gorm.DB, c *gin.Context, u models.User, familyID, deviceID string) (gin.H, *models.RefreshToken, error) {
+	roles := make([]string, 0, len(u.Roles))
+	for _, r := range u.Roles {
+		roles = append(roles, r.Name)
+	}
+
+	var err error
+	if familyID == "" {
+		familyID, err = auth.NewRandomID()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(h.RefreshTTL)
+
+	// Familias creadas antes de existir sesiones no tienen fila: se crea al primer refresh
+	sess := models.UserSession{
+		UserID:     u.ID,
+		FamilyID:   familyID,
+		DeviceID:   deviceID,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	if err := tx.Where("family_id = ?", familyID).FirstOrCreate(&sess).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Model(&sess).Updates(map[string]any{
+		"last_seen_at": now,
+		"expires_at":   expiresAt,
+		"ip":           c.ClientIP(),
+		"user_agent":   c.Request.UserAgent(),
+	}).Error; err != nil {
+		return nil, nil, err
+	}
+
+	issuedAt, err := h.Revocations.UserIssueTime(u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	access, err := auth.SignAccessToken(h.JWTConfig, u.ID, roles, sess.ID, issuedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plain, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rt := models.RefreshToken{
+		UserID:    u.ID,
+		TokenHash: hash,
+		FamilyID:  familyID,
+		DeviceID:  deviceID,
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		ExpiresAt: expiresAt,
+	}
+	if err := tx.Create(&rt).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return gin.H{
+		"access_token":       access,
+		"token_type":         "Bearer",
+		"expires_in":         int(h.JWTConfig.AccessTTL.Seconds()),
+		"refresh_token":      plain,
+		"refresh_expires_in": int(h.RefreshTTL.Seconds()),
+	}, &rt, nil
+}
+
+// Refresh rota el refresh token: marca el actual como usado y entrega uno nuevo de la misma familia.
+// Si se presenta un token ya usado, se revoca toda la familia (posible robo).
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash := auth.HashOpaqueToken(strings.TrimSpace(req.RefreshToken))
+
+	var current models.RefreshToken
+	if err := h.DB.Where("token_hash = ?", hash).First(&current).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token inválido"})
+		return
+	}
+
+	now := time.Now()
+
+	if current.RevokedAt != nil && current.UsedAt == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token revocado"})
+		return
+	}
+
+	if current.UsedAt != nil {
+		if err := h.Revocations.RevokeFamily(current.FamilyID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo revocar la sesión"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errRefreshReused.Error()})
+		return
+	}
+
+	if now.After(current.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token expirado"})
+		return
+	}
+
+	var u models.User
+	if err := h.DB.Preload("Roles").First(&u, current.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token inválido"})
+		return
+	}
+	if !u.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "usuario desactivado"})
+		return
+	}
+
+	var resp gin.H
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// Marcado condicional: si otra request ya lo usó, RowsAffected = 0
+		res := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errRefreshReused
+		}
+
+		out, next, err := h.issueTokens(tx, c, u, current.FamilyID, current.DeviceID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("id = ?", current.ID).
+			Update("replaced_by_id", next.ID).Error; err != nil {
+			return err
+		}
+
+		resp = out
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errRefreshReused) {
+			if err := h.Revocations.RevokeFamily(current.FamilyID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo revocar la sesión"})
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errRefreshReused.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo renovar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
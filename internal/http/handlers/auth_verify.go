@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"handsoft/internal/mail"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Modos de la política de login para cuentas con email sin verificar
+const (
+	UnverifiedLoginAllow = "allow" // se permite login (comportamiento histórico)
+	UnverifiedLoginGrace = "grace" // se permite login solo durante GracePeriod desde el registro
+	UnverifiedLoginBlock = "block" // no se permite login hasta verificar
+)
+
+type EmailVerificationPolicy struct {
+	Mode        string
+	GracePeriod time.Duration
+	TokenTTL    time.Duration
+}
+
+// allowsLogin indica si un usuario sin email verificado puede iniciar sesión.
+func (p EmailVerificationPolicy) allowsLogin(u models.User) bool {
+	if u.EmailVerifiedAt != nil {
+		return true
+	}
+	switch p.Mode {
+	case UnverifiedLoginBlock:
+		return false
+	case UnverifiedLoginGrace:
+		return time.Since(u.CreatedAt) <= p.GracePeriod
+	default:
+		return true
+	}
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyEmail marca el email del usuario como verificado.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		t, err := consumeUserToken(tx, strings.TrimSpace(req.Token), models.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", t.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+	if err != nil {
+		if errors.Is(err, errUserTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo verificar el email"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ResendVerification reenvía el correo de verificación.
+// Responde lo mismo exista o no el email.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	accepted := gin.H{"message": "si el email existe y no está verificado, enviaremos un nuevo correo"}
+
+	var u models.User
+	if err := h.DB.Where("email = ?", email).First(&u).Error; err != nil ||
+		!u.IsActive || u.EmailVerifiedAt != nil {
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+
+	// Un error aquí tampoco cambia la respuesta: un 500 revelaría que el email existe sin verificar
+	if err := h.sendVerificationEmail(c.Request.Context(), u); err != nil {
+		log.Printf("verify email: no se pudo enviar correo a user %d: %v", u.ID, err)
+	}
+
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+func (h *AuthHandler) sendVerificationEmail(ctx context.Context, u models.User) error {
+	token, err := createUserToken(h.DB, u.ID, models.TokenPurposeEmailVerification, h.EmailVerification.TokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.AppURL + "/verify-email?token=" + url.QueryEscape(token)
+	return h.Mailer.Send(ctx, mail.Message{
+		To:      []string{u.Email},
+		Subject: "Verifica tu email",
+		Text: fmt.Sprintf(
+			"Hola %s,\n\nConfirma tu email abriendo el siguiente link (válido por %d horas):\n\n%s\n",
+			u.Username, int(h.EmailVerification.TokenTTL.Hours()), link,
+		),
+	})
+}
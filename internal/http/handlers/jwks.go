@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"handsoft/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	Keys *auth.KeySet
+}
+
+// JWKS publica las llaves públicas de firma. Con HS256 (sin llaves asimétricas) la lista va vacía.
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	keys := []auth.JWK{}
+	if h.Keys != nil {
+		keys = h.Keys.JWKS()
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(auth.JWKSMaxAge.Seconds())))
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LoginProtection configura la protección contra fuerza bruta en login.
+type LoginProtection struct {
+	// Por cuenta: tras MaxFailures fallos seguidos se bloquea LockoutDuration
+	MaxFailures     int
+	LockoutDuration time.Duration
+
+	// Entre fallos se exige esperar BaseDelay * 2^(fallos-1), con tope MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// Por IP: más de IPMaxFailures fallos en IPWindow => 429
+	IPMaxFailures int
+	IPWindow      time.Duration
+}
+
+// recordLoginAttempt guarda el intento en el historial (errores solo se loguean).
+func recordLoginAttempt(db *gorm.DB, c *gin.Context, login string, userID *uint, success bool, reason string) {
+	a := models.LoginAttempt{
+		Login:     login,
+		UserID:    userID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+	}
+	if err := db.Create(&a).Error; err != nil {
+		log.Printf("login attempt: no se pudo registrar intento: %v", err)
+	}
+}
+
+// ipThrottled responde 429 si la IP acumula demasiados fallos recientes.
+func (h *AuthHandler) ipThrottled(c *gin.Context) bool {
+	p := h.LoginProtection
+	if p.IPMaxFailures <= 0 {
+		return false
+	}
+
+	var count int64
+	if err := h.DB.Model(&models.LoginAttempt{}).
+		Where("ip = ? AND success = ? AND created_at > ?", c.ClientIP(), false, time.Now().Add(-p.IPWindow)).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el intento"})
+		return true
+	}
+
+	if count >= int64(p.IPMaxFailures) {
+		tooManyAttempts(c, p.IPWindow)
+		return true
+	}
+	return false
+}
+
+// accountThrottled responde si la cuenta está bloqueada o si no pasó el delay progresivo.
+func (h *AuthHandler) accountThrottled(c *gin.Context, login string, u models.User) bool {
+	now := time.Now()
+
+	if u.LockedUntil != nil && now.Before(*u.LockedUntil) {
+		recordLoginAttempt(h.DB, c, login, &u.ID, false, "locked")
+		wait := u.LockedUntil.Sub(now)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusLocked, gin.H{
+			"error":       "cuenta bloqueada temporalmente",
+			"retry_after": int(math.Ceil(wait.Seconds())),
+		})
+		return true
+	}
+
+	if u.FailedLoginCount > 0 && u.LastFailedLoginAt != nil {
+		if wait := time.Until(u.LastFailedLoginAt.Add(h.LoginProtection.delayFor(u.FailedLoginCount))); wait > 0 {
+			recordLoginAttempt(h.DB, c, login, &u.ID, false, "throttled")
+			tooManyAttempts(c, wait)
+			return true
+		}
+	}
+
+	return false
+}
+
+// registerFailure suma un fallo a la cuenta y la bloquea si llega a MaxFailures.
+func (h *AuthHandler) registerFailure(c *gin.Context, login string, u models.User, reason string) {
+	recordLoginAttempt(h.DB, c, login, &u.ID, false, reason)
+
+	now := time.Now()
+	if err := h.DB.Model(&models.User{}).
+		Where("id = ?", u.ID).
+		Updates(map[string]any{
+			"failed_login_count":   gorm.Expr("failed_login_count + 1"),
+			"last_failed_login_at": now,
+		}).Error; err != nil {
+		log.Printf("login: no se pudo registrar fallo de user %d: %v", u.ID, err)
+		return
+	}
+
+	p := h.LoginProtection
+	if p.MaxFailures <= 0 {
+		return
+	}
+
+	// Bloqueo: se reinicia el contador para que al terminar el bloqueo parta de cero
+	if err := h.DB.Model(&models.User{}).
+		Where("id = ? AND failed_login_count >= ?", u.ID, p.MaxFailures).
+		Updates(map[string]any{
+			"failed_login_count": 0,
+			"locked_until":       now.Add(p.LockoutDuration),
+		}).Error; err != nil {
+		log.Printf("login: no se pudo bloquear user %d: %v", u.ID, err)
+	}
+}
+
+// registerSuccess limpia los contadores de fallos.
+func (h *AuthHandler) registerSuccess(c *gin.Context, login string, u models.User) {
+	recordLoginAttempt(h.DB, c, login, &u.ID, true, "")
+
+	if u.FailedLoginCount == 0 && u.LockedUntil == nil {
+		return
+	}
+	if err := h.DB.Model(&models.User{}).
+		Where("id = ?", u.ID).
+		Updates(map[string]any{
+			"failed_login_count":   0,
+			"last_failed_login_at": nil,
+			"locked_until":         nil,
+		}).Error; err != nil {
+		log.Printf("login: no se pudo limpiar fallos de user %d: %v", u.ID, err)
+	}
+}
+
+func (p LoginProtection) delayFor(failures int) time.Duration {
+	if p.BaseDelay <= 0 || failures <= 0 {
+		return 0
+	}
+	d := p.BaseDelay << uint(min(failures-1, 16))
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func tooManyAttempts(c *gin.Context, wait time.Duration) {
+	secs := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(secs))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "demasiados intentos, espera antes de reintentar",
+		"retry_after": secs,
+	})
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer        = "Handsoft"
+	recoveryCodeCount = 10
+	mfaChallengeTTL   = 5 * time.Minute
+)
+
+var (
+	errMFAAlreadyEnabled = errors.New("mfa ya está habilitado")
+	errMFANotEnrolled    = errors.New("no hay enrolamiento mfa pendiente")
+	errMFAInvalidCode    = errors.New("código mfa inválido")
+)
+
+// roleRequiresMFA indica si alguno de los roles del usuario exige segundo factor.
+func roleRequiresMFA(u models.User) bool {
+	for _, r := range u.Roles {
+		if r.RequiresMFA {
+			return true
+		}
+	}
+	return false
+}
+
+// startTOTPEnrollment genera (o regenera) un secreto pendiente de confirmación.
+func startTOTPEnrollment(db *gorm.DB, u models.User) (secret, uri string, err error) {
+	if u.TOTPEnabledAt != nil {
+		return "", "", errMFAAlreadyEnabled
+	}
+
+	secret, err = auth.NewTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := db.Model(&models.User{}).
+		Where("id = ?", u.ID).
+		Updates(map[string]any{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		return "", "", err
+	}
+
+	return secret, auth.TOTPURI(totpIssuer, u.Email, secret), nil
+}
+
+// confirmTOTPEnrollment valida el primer código, activa TOTP y genera códigos de recuperación.
+func confirmTOTPEnrollment(db *gorm.DB, u models.User, code string) ([]string, error) {
+	if u.TOTPEnabledAt != nil {
+		return nil, errMFAAlreadyEnabled
+	}
+	if u.TOTPSecret == "" {
+		return nil, errMFANotEnrolled
+	}
+
+	step, ok := auth.VerifyTOTP(u.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, errMFAInvalidCode
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", u.ID).
+			Updates(map[string]any{"totp_enabled_at": time.Now(), "totp_last_step": step}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, u.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// replaceRecoveryCodes borra los códigos anteriores y genera un set nuevo.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.MFARecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, models.MFARecoveryCode{UserID: userID, CodeHash: auth.HashRecoveryCode(code)})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// verifySecondFactor valida un código TOTP (sin reuso del mismo paso) o un código de recuperación.
+func verifySecondFactor(db *gorm.DB, u models.User, code, recoveryCode string) error {
+	if u.TOTPEnabledAt == nil {
+		return errMFANotEnrolled
+	}
+
+	if rc := strings.TrimSpace(recoveryCode); rc != "" {
+		res := db.Model(&models.MFARecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", u.ID, auth.HashRecoveryCode(rc)).
+			Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errMFAInvalidCode
+		}
+		return nil
+	}
+
+	step, ok := auth.VerifyTOTP(u.TOTPSecret, code, time.Now())
+	if !ok {
+		return errMFAInvalidCode
+	}
+
+	// Update condicional: solo avanza si el paso es nuevo (evita replay del mismo código)
+	res := db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", u.ID, step).
+		Update("totp_last_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errMFAInvalidCode
+	}
+	return nil
+}
+
+// disableTOTP apaga TOTP y borra los códigos de recuperación.
+func disableTOTP(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]any{"totp_secret": "", "totp_enabled_at": nil, "totp_last_step": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"handsoft/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// testDB abre un SQLite en memoria con las tablas pedidas. Alcanza para la lógica de updates
+// condicionales; lo específico de Postgres (CTEs recursivas, advisory locks) no se prueba acá.
+func testDB(t *testing.T, tables ...any) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{DisableForeignKeyConstraintWhenMigrating: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1) // cada conexión a :memory: es una base distinta
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	db := testDB(t, &models.MFARecoveryCode{})
+
+	now := time.Now()
+	u := models.User{ID: 1, TOTPEnabledAt: &now}
+
+	codes, err := replaceRecoveryCodes(db, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("%d códigos, se esperaban %d", len(codes), recoveryCodeCount)
+	}
+
+	if err := verifySecondFactor(db, u, "", codes[0]); err != nil {
+		t.Fatalf("primer uso: %v", err)
+	}
+	if err := verifySecondFactor(db, u, "", codes[0]); !errors.Is(err, errMFAInvalidCode) {
+		t.Fatalf("segundo uso: err = %v, se esperaba errMFAInvalidCode", err)
+	}
+
+	// Los demás códigos siguen sirviendo, una vez cada uno
+	if err := verifySecondFactor(db, u, "", codes[1]); err != nil {
+		t.Fatalf("otro código: %v", err)
+	}
+
+	// Un código de otro usuario no sirve
+	other := models.User{ID: 2, TOTPEnabledAt: &now}
+	if err := verifySecondFactor(db, other, "", codes[2]); !errors.Is(err, errMFAInvalidCode) {
+		t.Fatalf("código ajeno: err = %v", err)
+	}
+
+	// Regenerar invalida el set anterior
+	if _, err := replaceRecoveryCodes(db, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySecondFactor(db, u, "", codes[2]); !errors.Is(err, errMFAInvalidCode) {
+		t.Fatalf("código de un set reemplazado: err = %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errPasswordReused = errors.New("no puedes reutilizar una contraseña reciente")
+
+// setPassword valida la política (incluido el historial), guarda el hash nuevo (con hasher)
+// y mueve el hash anterior al historial. Debe llamarse dentro de una transacción.
+func setPassword(tx *gorm.DB, hasher auth.PasswordHasher, policy auth.PasswordPolicy, u models.User, plain string) error {
+	if err := policy.Validate(plain, u.Username, u.Email); err != nil {
+		return err
+	}
+
+	if policy.HistorySize > 0 {
+		if auth.CheckPassword(u.PasswordHash, plain) {
+			return errPasswordReused
+		}
+
+		var history []models.PasswordHistory
+		if err := tx.Where("user_id = ?", u.ID).
+			Order("id desc").
+			Limit(policy.HistorySize - 1).
+			Find(&history).Error; err != nil {
+			return err
+		}
+		for _, ph := range history {
+			if auth.CheckPassword(ph.PasswordHash, plain) {
+				return errPasswordReused
+			}
+		}
+	}
+
+	hash, err := hasher.Hash(plain)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.User{}).
+		Where("id = ?", u.ID).
+		Update("password_hash", hash).Error; err != nil {
+		return err
+	}
+
+	if policy.HistorySize <= 0 {
+		return nil
+	}
+
+	// La contraseña actual pasa al historial; se conservan solo las últimas HistorySize-1
+	// (junto con la vigente suman HistorySize)
+	if err := tx.Create(&models.PasswordHistory{UserID: u.ID, PasswordHash: u.PasswordHash}).Error; err != nil {
+		return err
+	}
+
+	var keep []uint
+	if err := tx.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", u.ID).
+		Order("id desc").
+		Limit(policy.HistorySize-1).
+		Pluck("id", &keep).Error; err != nil {
+		return err
+	}
+	q := tx.Where("user_id = ?", u.ID)
+	if len(keep) > 0 {
+		q = q.Where("id NOT IN ?", keep)
+	}
+	return q.Delete(&models.PasswordHistory{}).Error
+}
+
+// respondPasswordError responde 400 si err es un error de política/historial.
+// Devuelve false si err es otro tipo de error (el llamador decide).
+func respondPasswordError(c *gin.Context, err error) bool {
+	var pe *auth.PolicyError
+	switch {
+	case errors.As(err, &pe):
+		c.JSON(http.StatusBadRequest, gin.H{"error": pe.Error(), "violations": pe.Violations})
+		return true
+	case errors.Is(err, errPasswordReused):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSetPasswordHistory(t *testing.T) {
+	db := testDB(t, &models.User{}, &models.PasswordHistory{})
+
+	hasher := auth.PasswordHasher{BcryptCost: bcrypt.MinCost}
+	policy := auth.PasswordPolicy{MinLength: 8, HistorySize: 3} // la vigente + 2 anteriores
+
+	initial, err := hasher.Hash("Inicial#01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := models.User{Email: "jperez@example.com", Username: "jperez", PasswordHash: initial}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	change := func(plain string) error {
+		t.Helper()
+		var current models.User
+		if err := db.First(&current, u.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return setPassword(db, hasher, policy, current, plain)
+	}
+
+	steps := []struct {
+		plain string
+		want  error
+	}{
+		{"Inicial#01", errPasswordReused}, // la vigente
+		{"Segunda#02", nil},
+		{"Segunda#02", errPasswordReused},
+		{"Inicial#01", errPasswordReused}, // en el historial
+		{"Tercera#03", nil},
+		{"Cuarta#04", nil},                // Inicial#01 sale del historial
+		{"Segunda#02", errPasswordReused}, // sigue entre las 3 últimas
+		{"Inicial#01", nil},
+	}
+
+	for i, s := range steps {
+		if err := change(s.plain); !errors.Is(err, s.want) {
+			t.Fatalf("paso %d (%s): err = %v, se esperaba %v", i, s.plain, err, s.want)
+		}
+	}
+
+	var count int64
+	db.Model(&models.PasswordHistory{}).Where("user_id = ?", u.ID).Count(&count)
+	if count != int64(policy.HistorySize-1) {
+		t.Fatalf("historial con %d filas, se esperaban %d", count, policy.HistorySize-1)
+	}
+}
+
+func TestSetPasswordPolicyViolation(t *testing.T) {
+	db := testDB(t, &models.User{}, &models.PasswordHistory{})
+
+	policy := auth.PasswordPolicy{MinLength: 8, ForbidPersonalInfo: true}
+	u := models.User{Email: "jperez@example.com", Username: "jperez", PasswordHash: auth.UnusablePasswordHash}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	err := setPassword(db, auth.PasswordHasher{BcryptCost: bcrypt.MinCost}, policy, u, "clave-jperez")
+	var pe *auth.PolicyError
+	if !errors.As(err, &pe) {
+		t.Fatalf("err = %v, se esperaba *auth.PolicyError", err)
+	}
+
+	var stored models.User
+	db.First(&stored, u.ID)
+	if stored.PasswordHash != auth.UnusablePasswordHash {
+		t.Fatal("se guardó una contraseña que no cumple la política")
+	}
+}
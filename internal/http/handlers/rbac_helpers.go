@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"strings"
+
+	"handsoft/internal/http/middleware"
 	"handsoft/internal/models"
+	"handsoft/internal/permissions"
 
+	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
@@ -15,23 +20,63 @@ func isSuperAdminByRoles(db *gorm.DB, roleNames []string) (bool, error) {
 	return count > 0, err
 }
 
+// permissionsByRoles devuelve los permisos efectivos: los de los roles y los heredados de sus padres.
 func permissionsByRoles(db *gorm.DB, roleNames []string) ([]string, error) {
-	// Trae permisos por join role_permissions
-	var perms []models.Permission
-	err := db.Model(&models.Permission{}).
-		Select("DISTINCT permissions.id, permissions.code").
-		Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
-		Joins("JOIN roles r ON r.id = rp.role_id").
-		Where("r.name IN ?", roleNames).
-		Order("permissions.code asc").
-		Find(&perms).Error
+	return permissions.Effective(db, roleNames)
+}
+
+// roleGrantor representa lo que puede asignar quien hace el request: un super admin cualquier rol,
+// el resto solo roles cuyos permisos efectivos ya tiene (evita auto-escalar con users:roles).
+type roleGrantor struct {
+	isSuper bool
+	perms   map[string]bool
+}
+
+func (h *AdminHandler) callerGrantor(c *gin.Context) (*roleGrantor, error) {
+	isSuper, err := h.callerIsSuperAdmin(c)
+	if err != nil || isSuper {
+		return &roleGrantor{isSuper: isSuper}, err
+	}
+
+	names, _ := c.Get(middleware.CtxRolesKey)
+	roleNames, _ := names.([]string)
+	codes, err := permissionsByRoles(h.DB, roleNames)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &roleGrantor{perms: map[string]bool{}}
+	for _, code := range codes {
+		g.perms[code] = true
+	}
+	return g, nil
+}
+
+// missing devuelve los permisos efectivos de los roles que quien asigna no tiene
+// (ni exacto ni por comodín "modulo:*"). Vacío = puede asignarlos.
+func (g *roleGrantor) missing(db *gorm.DB, roles []models.Role) ([]string, error) {
+	if g.isSuper || len(roles) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		names = append(names, r.Name)
+	}
+	codes, err := permissionsByRoles(db, names)
 	if err != nil {
 		return nil, err
 	}
 
-	out := make([]string, 0, len(perms))
-	for _, p := range perms {
-		out = append(out, p.Code)
+	var out []string
+	for _, code := range codes {
+		if g.perms[code] {
+			continue
+		}
+		if m, _, ok := strings.Cut(code, ":"); ok && !strings.HasSuffix(code, ":*") && g.perms[m+":*"] {
+			continue
+		}
+		out = append(out, code)
 	}
 	return out, nil
 }
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// activeSessions lista las sesiones no revocadas ni expiradas de un usuario.
+func activeSessions(db *gorm.DB, userID uint) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func sessionJSON(s models.UserSession, currentID uint) gin.H {
+	return gin.H{
+		"id":           s.ID,
+		"device_id":    s.DeviceID,
+		"ip":           s.IP,
+		"user_agent":   s.UserAgent,
+		"created_at":   s.CreatedAt,
+		"last_seen_at": s.LastSeenAt,
+		"expires_at":   s.ExpiresAt,
+		"current":      currentID != 0 && s.ID == currentID,
+	}
+}
+
+var errSessionNotFound = errors.New("sesión no encontrada")
+
+// revokeUserSession revoca la sesión sid si pertenece al usuario (y sigue activa).
+func revokeUserSession(db *gorm.DB, revocations *auth.RevocationStore, userID uint, sid int) error {
+	var sess models.UserSession
+	res := db.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sid, userID).Limit(1).Find(&sess)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errSessionNotFound
+	}
+	return revocations.RevokeFamily(sess.FamilyID)
+}
+
+// MySessions lista los dispositivos donde el usuario tiene sesión iniciada.
+func (h *UserHandler) MySessions(c *gin.Context) {
+	claims, ok := claimsFromCtx(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autenticado"})
+		return
+	}
+
+	sessions, err := activeSessions(h.DB, claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudieron cargar sesiones"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, sessionJSON(s, claims.SessionID))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// RevokeMySession cierra una sesión propia (puede ser la actual).
+func (h *UserHandler) RevokeMySession(c *gin.Context) {
+	claims, ok := claimsFromCtx(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autenticado"})
+		return
+	}
+
+	sid, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": errSessionNotFound.Error()})
+		return
+	}
+
+	if err := revokeUserSession(h.DB, h.Revocations, claims.UserID, sid); err != nil {
+		if errors.Is(err, errSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo cerrar la sesión"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListUserSessions (admin) lista las sesiones activas de un usuario.
+func (h *AdminHandler) ListUserSessions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return
+	}
+
+	sessions, err := activeSessions(h.DB, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, sessionJSON(s, 0))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// RevokeUserSession (admin) cierra una sesión de un usuario.
+func (h *AdminHandler) RevokeUserSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return
+	}
+
+	sid, err := strconv.Atoi(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return
+	}
+
+	if err := revokeUserSession(h.DB, h.Revocations, uint(id), sid); err != nil {
+		if errors.Is(err, errSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session_not_found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_revoke_session"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -3,18 +3,32 @@ package handlers
 import (
 	"net/http"
 
+	"handsoft/internal/auth"
 	"handsoft/internal/http/middleware"
 	"handsoft/internal/models"
+	"handsoft/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type UserHandler struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	Revocations    *auth.RevocationStore
+	PasswordPolicy auth.PasswordPolicy
+	Hasher         auth.PasswordHasher
+
+	// Fotos de perfil (nil = subida deshabilitada)
+	Storage storage.Store
 }
 
 func (h *UserHandler) Me(c *gin.Context) {
+	// Token de service account (client credentials): no hay usuario detrás
+	if c.GetString(middleware.CtxSubTypeKey) == auth.SubTypeService {
+		h.serviceAccountMe(c)
+		return
+	}
+
 	userIDAny, ok := c.Get(middleware.CtxUserIDKey)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autenticado"})
@@ -38,44 +52,21 @@ func (h *UserHandler) Me(c *gin.Context) {
 		Preload("Address.Commune").
 		Preload("Address.Commune.City").
 		Preload("Address.Commune.City.Region").
-		Preload("Address.Commune.City.Region.Country")
+		Preload("Address.Commune.City.Region.Country").
+		Preload("Addresses", func(db *gorm.DB) *gorm.DB {
+			return db.Order("is_default desc, id asc")
+		}).
+		Preload("Addresses.Address")
 
 	if err := q.First(&u, userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "usuario no encontrado"})
 		return
 	}
 
-	// Roles
-	roles := make([]string, 0, len(u.Roles))
-	isSuperAdmin := false
-	for _, r := range u.Roles {
-		roles = append(roles, r.Name)
-		if r.IsSuperAdmin {
-			isSuperAdmin = true
-		}
-	}
-
-	// Permissions (si es super_admin => "*", si no => permisos por roles)
-	permissions := make([]string, 0)
-	if isSuperAdmin {
-		permissions = []string{"*"}
-	} else {
-		// Query: permisos por roles del usuario
-		var perms []models.Permission
-		if err := h.DB.Model(&models.Permission{}).
-			Select("DISTINCT permissions.id, permissions.code").
-			Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
-			Joins("JOIN roles r ON r.id = rp.role_id").
-			Where("r.name IN ?", roles).
-			Order("permissions.code asc").
-			Find(&perms).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error obteniendo permisos"})
-			return
-		}
-
-		for _, p := range perms {
-			permissions = append(permissions, p.Code)
-		}
+	roles, isSuperAdmin, permissions, err := rolesAndPermissions(h.DB, u.Roles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error obteniendo permisos"})
+		return
 	}
 
 	// Phones
@@ -88,6 +79,12 @@ func (h *UserHandler) Me(c *gin.Context) {
 		})
 	}
 
+	// Direcciones etiquetadas ("address" se mantiene con la por defecto)
+	addresses := make([]gin.H, 0, len(u.Addresses))
+	for _, ua := range u.Addresses {
+		addresses = append(addresses, userAddressJSON(ua))
+	}
+
 	// Address + Location
 	var address any = nil
 	location := gin.H{
@@ -98,17 +95,7 @@ func (h *UserHandler) Me(c *gin.Context) {
 	}
 
 	if u.Address != nil {
-		address = gin.H{
-			"id":                       u.Address.ID,
-			"street":                   u.Address.Street,
-			"street_number":            u.Address.StreetNumber,
-			"is_condominium":           u.Address.IsCondominium,
-			"condominium_house_number": u.Address.CondominiumHouseNumber,
-			"building_number":          u.Address.BuildingNumber,
-			"apartment_number":         u.Address.ApartmentNumber,
-			"extra":                    u.Address.Extra,
-			"commune_id":               u.Address.CommuneID,
-		}
+		address = addressJSON(*u.Address)
 
 		co := u.Address.Commune
 		location["commune"] = gin.H{"id": co.ID, "name": co.Name}
@@ -129,10 +116,22 @@ func (h *UserHandler) Me(c *gin.Context) {
 		}
 	}
 
+	avatarURL, avatarURLsBySize := avatarURLs(h.Storage, u.AvatarKey)
+
+	// Con token de impersonación se informa quién está actuando realmente
+	var impersonatedBy any = nil
+	if actorID, ok := c.Get(middleware.CtxActorIDKey); ok {
+		impersonatedBy = actorID
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"id":             u.ID,
+		"sub_type":       auth.SubTypeUser,
 		"email":          u.Email,
 		"username":       u.Username,
+		"rut":            u.RUT,
+		"avatar_url":     avatarURL,
+		"avatar_urls":    avatarURLsBySize,
 		"isActive":       u.IsActive,
 		"is_super_admin": isSuperAdmin,
 		"roles":          roles,
@@ -141,8 +140,65 @@ func (h *UserHandler) Me(c *gin.Context) {
 		"contact": gin.H{
 			"full_name": u.Contacts.FullName,
 		},
-		"phones":   phones,
-		"address":  address,
-		"location": location,
+		"phones":    phones,
+		"address":   address,
+		"addresses": addresses,
+		"location":  location,
+
+		"impersonated_by": impersonatedBy,
+	})
+}
+
+// serviceAccountMe describe la service account dueña del token (mismo formato de roles/permisos que un usuario).
+func (h *UserHandler) serviceAccountMe(c *gin.Context) {
+	accountID, _ := c.Get(middleware.CtxServiceAccountIDKey)
+
+	var sa models.ServiceAccount
+	if err := h.DB.Preload("Roles").First(&sa, accountID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "service account no encontrada"})
+		return
+	}
+
+	roles, isSuperAdmin, permissions, err := rolesAndPermissions(h.DB, sa.Roles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error obteniendo permisos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":             sa.ID,
+		"sub_type":       auth.SubTypeService,
+		"name":           sa.Name,
+		"client_id":      sa.ClientID,
+		"isActive":       sa.IsActive,
+		"is_super_admin": isSuperAdmin,
+		"roles":          roles,
+		"permissions":    permissions,
 	})
 }
+
+// rolesAndPermissions devuelve los nombres de rol y los permisos efectivos
+// (si algún rol es super_admin => "*", si no => permisos por roles).
+func rolesAndPermissions(db *gorm.DB, rs []models.Role) ([]string, bool, []string, error) {
+	roles := make([]string, 0, len(rs))
+	isSuperAdmin := false
+	for _, r := range rs {
+		roles = append(roles, r.Name)
+		if r.IsSuperAdmin {
+			isSuperAdmin = true
+		}
+	}
+
+	permissions := make([]string, 0)
+	if isSuperAdmin {
+		return roles, true, []string{"*"}, nil
+	}
+
+	// Permisos efectivos: incluye los heredados de los roles padre
+	perms, err := permissionsByRoles(db, roles)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	permissions = append(permissions, perms...)
+	return roles, false, permissions, nil
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type userAddressReq struct {
+	AddressInput
+	Label     string `json:"label" binding:"required,oneof=home billing delivery work"`
+	IsDefault bool   `json:"is_default"`
+}
+
+var errUserAddressNotFound = errors.New("dirección no encontrada")
+
+func userAddressJSON(ua models.UserAddress) gin.H {
+	var address any = nil
+	if ua.Address != nil {
+		address = addressJSON(*ua.Address)
+	}
+	return gin.H{
+		"id":         ua.ID,
+		"label":      ua.Label,
+		"is_default": ua.IsDefault,
+		"address":    address,
+	}
+}
+
+// MyAddresses lista las direcciones del usuario actual (la por defecto primero).
+func (h *UserHandler) MyAddresses(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var list []models.UserAddress
+	if err := h.DB.Preload("Address").
+		Where("user_id = ?", u.ID).
+		Order("is_default desc, id asc").
+		Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudieron cargar las direcciones"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(list))
+	for _, ua := range list {
+		out = append(out, userAddressJSON(ua))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// AddMyAddress agrega una dirección etiquetada. La primera queda por defecto; si viene
+// is_default=true, pasa a ser la por defecto y la anterior deja de serlo.
+func (h *UserHandler) AddMyAddress(c *gin.Context) {
+	var req userAddressReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	ua := models.UserAddress{UserID: u.ID, Label: req.Label}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		addr, err := findOrCreateAddress(tx, req.AddressInput)
+		if err != nil {
+			return err
+		}
+		ua.AddressID = addr.ID
+
+		if err := tx.Create(&ua).Error; err != nil {
+			return err
+		}
+		if req.IsDefault {
+			return setDefaultAddress(tx, u.ID, ua.ID)
+		}
+		return ensureDefaultAddress(tx, u.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errCommuneNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo guardar la dirección"})
+		return
+	}
+
+	h.DB.Preload("Address").First(&ua, ua.ID)
+	c.JSON(http.StatusCreated, userAddressJSON(ua))
+}
+
+// UpdateMyAddressByID reemplaza una dirección etiquetada. is_default=true la vuelve la por defecto;
+// para dejar de serlo hay que marcar otra (siempre hay exactamente una si el usuario tiene direcciones).
+func (h *UserHandler) UpdateMyAddressByID(c *gin.Context) {
+	var req userAddressReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var ua models.UserAddress
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := findMyAddress(tx, u.ID, c.Param("id"), &ua); err != nil {
+			return err
+		}
+
+		addr, err := findOrCreateAddress(tx, req.AddressInput)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&ua).Updates(map[string]any{
+			"address_id": addr.ID,
+			"label":      req.Label,
+		}).Error; err != nil {
+			return err
+		}
+
+		if req.IsDefault {
+			return setDefaultAddress(tx, u.ID, ua.ID)
+		}
+		// Re-sincroniza users.address_id por si cambió la dirección por defecto
+		return ensureDefaultAddress(tx, u.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errUserAddressNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, errCommuneNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo guardar la dirección"})
+		return
+	}
+
+	h.DB.Preload("Address").First(&ua, ua.ID)
+	c.JSON(http.StatusOK, userAddressJSON(ua))
+}
+
+// DeleteMyAddress borra una dirección etiquetada (la Address compartida se conserva).
+// Si era la por defecto, la más antigua que quede pasa a serlo.
+func (h *UserHandler) DeleteMyAddress(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var ua models.UserAddress
+		if err := findMyAddress(tx, u.ID, c.Param("id"), &ua); err != nil {
+			return err
+		}
+		if err := tx.Delete(&ua).Error; err != nil {
+			return err
+		}
+		return ensureDefaultAddress(tx, u.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errUserAddressNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo borrar la dirección"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func findMyAddress(tx *gorm.DB, userID uint, rawID string, ua *models.UserAddress) error {
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		return errUserAddressNotFound
+	}
+
+	res := tx.Where("id = ? AND user_id = ?", id, userID).Limit(1).Find(ua)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errUserAddressNotFound
+	}
+	return nil
+}
+
+// setDefaultAddress deja userAddressID como única dirección por defecto del usuario
+// y la refleja en users.address_id.
+func setDefaultAddress(tx *gorm.DB, userID, userAddressID uint) error {
+	if err := tx.Model(&models.UserAddress{}).
+		Where("user_id = ? AND id <> ? AND is_default = ?", userID, userAddressID, true).
+		Update("is_default", false).Error; err != nil {
+		return err
+	}
+
+	var ua models.UserAddress
+	if err := tx.First(&ua, userAddressID).Error; err != nil {
+		return err
+	}
+	if !ua.IsDefault {
+		if err := tx.Model(&ua).Update("is_default", true).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("address_id", ua.AddressID).Error
+}
+
+// ensureDefaultAddress garantiza exactamente una dirección por defecto si el usuario tiene direcciones
+// (si no hay ninguna marcada, la más antigua). Sin direcciones, users.address_id queda en NULL.
+func ensureDefaultAddress(tx *gorm.DB, userID uint) error {
+	var list []models.UserAddress
+	if err := tx.Where("user_id = ?", userID).Order("id asc").Find(&list).Error; err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Update("address_id", nil).Error
+	}
+
+	defaultID := list[0].ID
+	for _, ua := range list {
+		if ua.IsDefault {
+			defaultID = ua.ID
+			break
+		}
+	}
+	return setDefaultAddress(tx, userID, defaultID)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"handsoft/internal/avatar"
+	"handsoft/internal/models"
+	"handsoft/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errAvatarTooLarge = errors.New("la imagen supera el tamaño máximo")
+
+// avatarFileKey es la key de la miniatura de un tamaño ("avatars/12/<random>-64.jpg").
+func avatarFileKey(prefix string, size int) string {
+	return fmt.Sprintf("%s-%d.jpg", prefix, size)
+}
+
+// avatarURLs devuelve la URL principal (miniatura más grande) y las de cada tamaño; nil si no hay foto.
+func avatarURLs(store storage.Store, prefix string) (any, any) {
+	if store == nil || prefix == "" {
+		return nil, nil
+	}
+
+	urls := gin.H{}
+	for _, size := range avatar.Sizes {
+		urls[strconv.Itoa(size)] = store.URL(avatarFileKey(prefix, size))
+	}
+	return store.URL(avatarFileKey(prefix, avatar.Sizes[len(avatar.Sizes)-1])), urls
+}
+
+// deleteAvatarFiles borra las miniaturas (best effort: un archivo huérfano no es grave).
+func deleteAvatarFiles(ctx context.Context, store storage.Store, prefix string) {
+	if store == nil || prefix == "" {
+		return
+	}
+	for _, size := range avatar.Sizes {
+		if err := store.Delete(ctx, avatarFileKey(prefix, size)); err != nil {
+			log.Printf("avatar: no se pudo borrar %s: %v", avatarFileKey(prefix, size), err)
+		}
+	}
+}
+
+// UploadMyAvatar reemplaza la foto de perfil. Acepta multipart (campo "avatar") o la imagen
+// directo en el body (Content-Type image/jpeg o image/png). Se guardan solo las miniaturas.
+func (h *UserHandler) UploadMyAvatar(c *gin.Context) {
+	if h.Storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "almacenamiento de archivos no configurado"})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	data, declaredType, err := readAvatarUpload(c)
+	if err != nil {
+		if errors.Is(err, errAvatarTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no se pudo leer la imagen"})
+		return
+	}
+
+	thumbs, err := avatar.Thumbnails(data, declaredType)
+	if err != nil {
+		switch {
+		case errors.Is(err, avatar.ErrTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, avatar.ErrUnsupportedType):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		case errors.Is(err, avatar.ErrInvalidImage), errors.Is(err, avatar.ErrTooManyPixels):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo procesar la imagen"})
+		}
+		return
+	}
+
+	// Prefijo nuevo en cada subida: las URLs cambian y no quedan cacheadas con la foto anterior
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo guardar la imagen"})
+		return
+	}
+	prefix := fmt.Sprintf("avatars/%d/%s", u.ID, hex.EncodeToString(random))
+
+	ctx := c.Request.Context()
+	for _, size := range avatar.Sizes {
+		if err := h.Storage.Put(ctx, avatarFileKey(prefix, size), thumbs[size], "image/jpeg"); err != nil {
+			log.Printf("avatar: no se pudo guardar %s: %v", avatarFileKey(prefix, size), err)
+			deleteAvatarFiles(context.WithoutCancel(ctx), h.Storage, prefix)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo guardar la imagen"})
+			return
+		}
+	}
+
+	if err := h.DB.Model(&models.User{}).
+		Where("id = ?", u.ID).
+		Update("avatar_key", prefix).Error; err != nil {
+		deleteAvatarFiles(context.WithoutCancel(ctx), h.Storage, prefix)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo guardar la imagen"})
+		return
+	}
+	deleteAvatarFiles(context.WithoutCancel(ctx), h.Storage, u.AvatarKey)
+
+	url, urls := avatarURLs(h.Storage, prefix)
+	c.JSON(http.StatusOK, gin.H{"avatar_url": url, "avatar_urls": urls})
+}
+
+// DeleteMyAvatar quita la foto de perfil.
+func (h *UserHandler) DeleteMyAvatar(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if u.AvatarKey == "" {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if err := h.DB.Model(&models.User{}).
+		Where("id = ?", u.ID).
+		Update("avatar_key", "").Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo quitar la imagen"})
+		return
+	}
+	deleteAvatarFiles(c.Request.Context(), h.Storage, u.AvatarKey)
+
+	c.Status(http.StatusNoContent)
+}
+
+// readAvatarUpload lee la imagen (y el Content-Type que informó el cliente) con tope de avatar.MaxBytes.
+func readAvatarUpload(c *gin.Context) ([]byte, string, error) {
+	// Margen para el overhead de multipart; el tope real se valida sobre la imagen
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, avatar.MaxBytes+64<<10)
+
+	var r io.Reader = c.Request.Body
+	declaredType := c.ContentType()
+	if strings.HasPrefix(declaredType, "multipart/") {
+		fh, err := c.FormFile("avatar")
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return nil, "", errAvatarTooLarge
+			}
+			return nil, "", err
+		}
+		if fh.Size > avatar.MaxBytes {
+			return nil, "", errAvatarTooLarge
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		r = f
+		declaredType = fh.Header.Get("Content-Type")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, avatar.MaxBytes+1))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, "", errAvatarTooLarge
+		}
+		return nil, "", err
+	}
+	if len(data) > avatar.MaxBytes {
+		return nil, "", errAvatarTooLarge
+	}
+
+	// application/octet-stream = el cliente no sabe el tipo: se valida solo por contenido
+	if declaredType == "application/octet-stream" {
+		declaredType = ""
+	}
+	return data, declaredType, nil
+}
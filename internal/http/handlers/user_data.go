@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/http/middleware"
+	"handsoft/internal/models"
+	"handsoft/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errAlreadyAnonymized = errors.New("already_anonymized")
+
+// userDataSection es un archivo del export de datos personales (en el ZIP: <Name>.json).
+type userDataSection struct {
+	Name string
+	Data any
+}
+
+// ExportMyData descarga todos los datos personales del usuario actual (ver writeUserData).
+func (h *UserHandler) ExportMyData(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	sections, err := userDataExport(h.DB, h.Storage, u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudieron exportar los datos"})
+		return
+	}
+	writeUserData(c, u.ID, sections)
+}
+
+// ExportUserData descarga los datos personales de un usuario (incluye usuarios borrados).
+// Queda registrado en la auditoría.
+func (h *AdminHandler) ExportUserData(c *gin.Context) {
+	u, ok := h.findAnyUser(c)
+	if !ok {
+		return
+	}
+	if !h.guardSuperAdminTarget(c, u.ID) {
+		return
+	}
+
+	sections, err := userDataExport(h.DB, h.Storage, u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_export_user_data"})
+		return
+	}
+
+	h.auditUserAction(c, "user.data_export", u.ID, http.StatusOK)
+	writeUserData(c, u.ID, sections)
+}
+
+// AnonymizeUser borra los datos personales de un usuario de forma irreversible.
+// El usuario (y su id) se conserva para no romper auditoría ni registros de bodega; ver anonymizeUser.
+func (h *AdminHandler) AnonymizeUser(c *gin.Context) {
+	u, ok := h.findAnyUser(c)
+	if !ok {
+		return
+	}
+	if !h.guardSuperAdminTarget(c, u.ID) {
+		return
+	}
+	if u.ID == c.GetUint(middleware.CtxUserIDKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot_anonymize_self"})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockSuperAdminRoles(tx); err != nil {
+			return err
+		}
+		if err := anonymizeUser(tx, *u); err != nil {
+			return err
+		}
+		return ensureSuperAdminRemains(tx)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errAlreadyAnonymized), errors.Is(err, errLastSuperAdmin):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot_anonymize_user"})
+		}
+		return
+	}
+
+	if err := h.Revocations.RevokeUser(u.ID); err != nil {
+		log.Printf("admin: no se pudieron revocar sesiones de user %d: %v", u.ID, err)
+	}
+	deleteAvatarFiles(c.Request.Context(), h.Storage, u.AvatarKey)
+
+	h.auditUserAction(c, "user.anonymize", u.ID, http.StatusNoContent)
+	c.Status(http.StatusNoContent)
+}
+
+// findAnyUser es findUser incluyendo usuarios borrados (lógico).
+func (h *AdminHandler) findAnyUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id"})
+		return nil, false
+	}
+
+	var u models.User
+	if err := h.DB.Unscoped().First(&u, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user_not_found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db_error"})
+		return nil, false
+	}
+	return &u, true
+}
+
+func (h *AdminHandler) auditUserAction(c *gin.Context, action string, userID uint, status int) {
+	actorID := c.GetUint(middleware.CtxUserIDKey)
+	entry := models.AuditLog{
+		Action:        action,
+		ActorID:       &actorID,
+		SubjectUserID: &userID,
+		Method:        c.Request.Method,
+		Path:          c.Request.URL.Path,
+		Status:        status,
+		IP:            c.ClientIP(),
+		UserAgent:     c.Request.UserAgent(),
+	}
+	if v, ok := c.Get(middleware.CtxAPIClientKey); ok {
+		if info, ok := v.(middleware.APIClientInfo); ok && info.ID != 0 {
+			entry.APIClientID = &info.ID
+		}
+	}
+	if err := h.DB.Create(&entry).Error; err != nil {
+		log.Printf("audit: no se pudo registrar %s de user %d: %v", action, userID, err)
+	}
+}
+
+// writeUserData responde el export como ZIP (un JSON por sección) o, con ?format=json, como un solo JSON.
+func writeUserData(c *gin.Context, userID uint, sections []userDataSection) {
+	if c.Query("format") == "json" {
+		out := gin.H{}
+		for _, s := range sections {
+			out[s.Name] = s.Data
+		}
+		c.JSON(http.StatusOK, out)
+		return
+	}
+
+	filename := fmt.Sprintf("user-%d-data-%s.zip", userID, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	for _, s := range sections {
+		f, err := zw.Create(s.Name + ".json")
+		if err != nil {
+			log.Printf("data export: no se pudo escribir %s de user %d: %v", s.Name, userID, err)
+			return
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(s.Data); err != nil {
+			log.Printf("data export: no se pudo escribir %s de user %d: %v", s.Name, userID, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("data export: no se pudo cerrar zip de user %d: %v", userID, err)
+	}
+}
+
+// userDataExport junta todo lo vinculado al usuario: perfil, contacto, teléfonos, direcciones,
+// roles, alcances, cuentas SSO, sesiones, historial de login y auditoría.
+func userDataExport(db *gorm.DB, store storage.Store, userID uint) ([]userDataSection, error) {
+	var u models.User
+	if err := db.Unscoped().
+		Preload("Contacts").
+		Preload("Phones").
+		Preload("Roles").
+		Preload("Commune").
+		Preload("Addresses", func(db *gorm.DB) *gorm.DB {
+			return db.Order("is_default desc, id asc")
+		}).
+		Preload("Addresses.Address").
+		Preload("Addresses.Address.Commune").
+		First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var commune any = nil
+	if u.Commune != nil {
+		commune = gin.H{"id": u.Commune.ID, "name": u.Commune.Name}
+	}
+	_, avatarURLsBySize := avatarURLs(store, u.AvatarKey)
+	profile := gin.H{
+		"id":                u.ID,
+		"email":             u.Email,
+		"username":          u.Username,
+		"rut":               u.RUT,
+		"is_active":         u.IsActive,
+		"avatar_urls":       avatarURLsBySize,
+		"email_verified_at": u.EmailVerifiedAt,
+		"mfa_enabled":       u.TOTPEnabledAt != nil,
+		"commune":           commune,
+		"created_at":        u.CreatedAt,
+		"updated_at":        u.UpdatedAt,
+		"anonymized_at":     u.AnonymizedAt,
+	}
+	if u.DeletedAt.Valid {
+		profile["deleted_at"] = u.DeletedAt.Time
+	}
+
+	contact := gin.H{"full_name": u.Contacts.FullName}
+
+	phones := make([]gin.H, 0, len(u.Phones))
+	for _, p := range u.Phones {
+		phones = append(phones, phoneJSON(p))
+	}
+
+	addresses := make([]gin.H, 0, len(u.Addresses))
+	for _, ua := range u.Addresses {
+		a := userAddressJSON(ua)
+		if ua.Address != nil {
+			a["commune"] = ua.Address.Commune.Name
+		}
+		addresses = append(addresses, a)
+	}
+
+	roles := make([]string, 0, len(u.Roles))
+	for _, r := range u.Roles {
+		roles = append(roles, r.Name)
+	}
+
+	var scopeRows []models.UserScope
+	if err := db.Where("user_id = ?", userID).Order("id asc").Find(&scopeRows).Error; err != nil {
+		return nil, err
+	}
+	scopes := make([]gin.H, 0, len(scopeRows))
+	for _, s := range scopeRows {
+		scopes = append(scopes, gin.H{
+			"space_id":     s.SpaceID,
+			"warehouse_id": s.WarehouseID,
+			"created_at":   s.CreatedAt,
+		})
+	}
+
+	var identityRows []models.UserIdentity
+	if err := db.Where("user_id = ?", userID).Order("id asc").Find(&identityRows).Error; err != nil {
+		return nil, err
+	}
+	identities := make([]gin.H, 0, len(identityRows))
+	for _, id := range identityRows {
+		identities = append(identities, gin.H{
+			"provider":      id.Provider,
+			"email":         id.Email,
+			"created_at":    id.CreatedAt,
+			"last_login_at": id.LastLoginAt,
+		})
+	}
+
+	var sessionRows []models.UserSession
+	if err := db.Where("user_id = ?", userID).Order("id desc").Find(&sessionRows).Error; err != nil {
+		return nil, err
+	}
+	sessions := make([]gin.H, 0, len(sessionRows))
+	for _, s := range sessionRows {
+		sessions = append(sessions, gin.H{
+			"device_id":    s.DeviceID,
+			"user_agent":   s.UserAgent,
+			"ip":           s.IP,
+			"created_at":   s.CreatedAt,
+			"last_seen_at": s.LastSeenAt,
+			"revoked_at":   s.RevokedAt,
+		})
+	}
+
+	var attemptRows []models.LoginAttempt
+	if err := db.Where("user_id = ?", userID).Order("id desc").Find(&attemptRows).Error; err != nil {
+		return nil, err
+	}
+	logins := make([]gin.H, 0, len(attemptRows))
+	for _, a := range attemptRows {
+		logins = append(logins, gin.H{
+			"created_at": a.CreatedAt,
+			"login":      a.Login,
+			"ip":         a.IP,
+			"user_agent": a.UserAgent,
+			"success":    a.Success,
+			"reason":     a.Reason,
+		})
+	}
+
+	var auditRows []models.AuditLog
+	if err := db.Where("actor_id = ? OR subject_user_id = ?", userID, userID).
+		Order("id desc").
+		Find(&auditRows).Error; err != nil {
+		return nil, err
+	}
+	audit := make([]gin.H, 0, len(auditRows))
+	for _, a := range auditRows {
+		entry := gin.H{
+			"created_at": a.CreatedAt,
+			"action":     a.Action,
+			"method":     a.Method,
+			"path":       a.Path,
+			"status":     a.Status,
+			"as_actor":   a.ActorID != nil && *a.ActorID == userID,
+		}
+		// IP y navegador solo de acciones propias (las del admin no son datos del usuario)
+		if a.ActorID != nil && *a.ActorID == userID {
+			entry["ip"] = a.IP
+			entry["user_agent"] = a.UserAgent
+		}
+		audit = append(audit, entry)
+	}
+
+	return []userDataSection{
+		{Name: "profile", Data: profile},
+		{Name: "contact", Data: contact},
+		{Name: "phones", Data: phones},
+		{Name: "addresses", Data: addresses},
+		{Name: "roles", Data: roles},
+		{Name: "scopes", Data: scopes},
+		{Name: "identities", Data: identities},
+		{Name: "sessions", Data: sessions},
+		{Name: "login_history", Data: logins},
+		{Name: "audit", Data: audit},
+	}, nil
+}
+
+// anonymizeUser borra los datos personales del usuario dentro de tx. La fila del usuario se conserva
+// (mismo id, borrada lógicamente y sin roles) para que auditoría, historial de login y registros
+// que apunten a ella sigan siendo consistentes; solo se limpian los campos que identifican a la persona.
+func anonymizeUser(tx *gorm.DB, u models.User) error {
+	if u.AnonymizedAt != nil {
+		return errAlreadyAnonymized
+	}
+
+	now := time.Now()
+	alias := fmt.Sprintf("anon-%d", u.ID)
+	aliasEmail := alias + "@anonymized.invalid"
+
+	// Direcciones propias, para borrar después las que queden sin uso
+	var addressIDs []uint
+	if err := tx.Model(&models.UserAddress{}).
+		Where("user_id = ?", u.ID).
+		Pluck("address_id", &addressIDs).Error; err != nil {
+		return err
+	}
+	if u.AddressID != nil {
+		addressIDs = append(addressIDs, *u.AddressID)
+	}
+
+	if err := tx.Unscoped().Model(&models.User{}).
+		Where("id = ?", u.ID).
+		Updates(map[string]any{
+			"email":                aliasEmail,
+			"username":             alias,
+			"rut":                  nil,
+			"password_hash":        auth.UnusablePasswordHash,
+			"is_active":            false,
+			"email_verified_at":    nil,
+			"totp_secret":          "",
+			"totp_enabled_at":      nil,
+			"totp_last_step":       0,
+			"failed_login_count":   0,
+			"last_failed_login_at": nil,
+			"locked_until":         nil,
+			"tokens_valid_after":   now,
+			"commune_id":           nil,
+			"address_id":           nil,
+			"avatar_key":           "",
+			"anonymized_at":        now,
+		}).Error; err != nil {
+		return err
+	}
+	if !u.DeletedAt.Valid {
+		if err := tx.Delete(&models.User{}, u.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&models.Contact{}).
+		Where("user_id = ?", u.ID).
+		Update("full_name", "").Error; err != nil {
+		return err
+	}
+
+	// Datos propios del usuario que no hacen falta para la integridad: se borran
+	for _, m := range []any{
+		&models.UserPhone{},
+		&models.UserAddress{},
+		&models.PasswordHistory{},
+		&models.UserToken{},
+		&models.MFARecoveryCode{},
+		&models.UserIdentity{},
+		&models.RefreshToken{},
+		&models.UserSession{},
+		&models.UserScope{},
+	} {
+		if err := tx.Where("user_id = ?", u.ID).Delete(m).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", u.ID).Error; err != nil {
+		return err
+	}
+
+	// Direcciones que ya no usa nadie (son compartidas: solo se borran si quedaron huérfanas)
+	if len(addressIDs) > 0 {
+		if err := tx.Where("id IN ?", addressIDs).
+			Where("id NOT IN (SELECT address_id FROM users WHERE address_id IS NOT NULL)").
+			Where("id NOT IN (SELECT address_id FROM user_addresses)").
+			Delete(&models.Address{}).Error; err != nil {
+			return err
+		}
+	}
+
+	// Historial de login y auditoría se conservan, sin IP ni navegador del usuario
+	if err := tx.Model(&models.LoginAttempt{}).
+		Where("user_id = ? OR login IN ?", u.ID, []string{u.Email, u.Username}).
+		Updates(map[string]any{"login": alias, "ip": "", "user_agent": ""}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.AuditLog{}).
+		Where("actor_id = ?", u.ID).
+		Updates(map[string]any{"ip": "", "user_agent": ""}).Error; err != nil {
+		return err
+	}
+
+	// Invitaciones enviadas a su email
+	return tx.Model(&models.Invitation{}).
+		Where("accepted_user_id = ? OR email = ?", u.ID, u.Email).
+		Update("email", aliasEmail).Error
+}
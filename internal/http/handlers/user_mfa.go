@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type mfaCodeReq struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// currentUser carga el usuario autenticado (con roles) o responde el error.
+func (h *UserHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	claims, ok := claimsFromCtx(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autenticado"})
+		return nil, false
+	}
+
+	var u models.User
+	if err := h.DB.Preload("Roles").First(&u, claims.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "usuario no encontrado"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo cargar el usuario"})
+		return nil, false
+	}
+	return &u, true
+}
+
+// MFAStatus indica si el usuario tiene TOTP y si su rol lo exige.
+func (h *UserHandler) MFAStatus(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var remaining int64
+	if err := h.DB.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", u.ID).
+		Count(&remaining).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo consultar mfa"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"totp_enabled":             u.TOTPEnabledAt != nil,
+		"required_by_role":         roleRequiresMFA(*u),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// EnrollTOTP genera un secreto TOTP pendiente de confirmar.
+func (h *UserHandler) EnrollTOTP(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := startTOTPEnrollment(h.DB, *u)
+	if err != nil {
+		if errors.Is(err, errMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo iniciar el enrolamiento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// ConfirmTOTP activa TOTP con el primer código y entrega los códigos de recuperación.
+func (h *UserHandler) ConfirmTOTP(c *gin.Context) {
+	var req mfaCodeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := confirmTOTPEnrollment(h.DB, *u, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, errMFAInvalidCode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errMFAAlreadyEnabled), errors.Is(err, errMFANotEnrolled):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo confirmar el enrolamiento"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTP apaga TOTP (requiere un código vigente). No se permite si algún rol lo exige.
+func (h *UserHandler) DisableTOTP(c *gin.Context) {
+	var req mfaCodeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if roleRequiresMFA(*u) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "tu rol exige mfa"})
+		return
+	}
+
+	if err := verifySecondFactor(h.DB, *u, req.Code, ""); err != nil {
+		if errors.Is(err, errMFAInvalidCode) || errors.Is(err, errMFANotEnrolled) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMFAInvalidCode.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el código"})
+		return
+	}
+
+	if err := disableTOTP(h.DB, u.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo deshabilitar mfa"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes reemplaza los códigos de recuperación (requiere un código TOTP vigente).
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req mfaCodeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := verifySecondFactor(h.DB, *u, req.Code, ""); err != nil {
+		if errors.Is(err, errMFAInvalidCode) || errors.Is(err, errMFANotEnrolled) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMFAInvalidCode.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el código"})
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(h.DB, u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudieron generar códigos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"handsoft/internal/auth"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type changePasswordReq struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,max=72"`
+}
+
+// ChangePassword cambia la contraseña del usuario actual (requiere la contraseña vigente)
+// y cierra todas sus sesiones.
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req changePasswordReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !auth.CheckPassword(u.PasswordHash, req.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "contraseña actual incorrecta"})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		return setPassword(tx, h.Hasher, h.PasswordPolicy, *u, req.NewPassword)
+	})
+	if err != nil {
+		if respondPasswordError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo cambiar la contraseña"})
+		return
+	}
+
+	if err := h.Revocations.RevokeUser(u.ID); err != nil {
+		log.Printf("change password: no se pudieron revocar sesiones de user %d: %v", u.ID, err)
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type updateMeReq struct {
+	FullName *string `json:"full_name" binding:"omitempty,max=120"`
+	Username *string `json:"username" binding:"omitempty,min=3,max=32,excludes=@"`
+	RUT      *string `json:"rut"` // "" lo borra; se valida con normalizeRUT
+}
+
+type phoneReq struct {
+	Label  string `json:"label" binding:"required,oneof=mobile work home"`
+	Number string `json:"number" binding:"required,max=32"`
+	IsMain bool   `json:"is_main"`
+}
+
+var errPhoneNotFound = errors.New("teléfono no encontrado")
+var errUsernameTaken = errors.New("username ya existe")
+var errRUTTaken = errors.New("rut ya registrado")
+
+func phoneJSON(p models.UserPhone) gin.H {
+	return gin.H{
+		"id":      p.ID,
+		"label":   p.Label,
+		"number":  p.Number,
+		"is_main": p.IsMain,
+	}
+}
+
+// UpdateMe cambia el nombre (Contact.FullName) y/o el username del usuario actual.
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	var req updateMeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// "" borra el RUT (value nil); cualquier otro valor debe ser un RUT válido
+	var rutValue *string
+	if req.RUT != nil {
+		value, err := normalizeRUT(*req.RUT)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rutValue = value
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if req.Username != nil {
+			username := strings.TrimSpace(*req.Username)
+			if username != u.Username {
+				// Incluye usuarios borrados: su username sigue reservado
+				taken, err := userFieldTaken(tx, "username", username, u.ID)
+				if err != nil {
+					return err
+				}
+				if taken {
+					return errUsernameTaken
+				}
+				if err := tx.Model(u).Update("username", username).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if req.RUT != nil {
+			if rutValue != nil {
+				taken, err := userFieldTaken(tx, "rut", *rutValue, u.ID)
+				if err != nil {
+					return err
+				}
+				if taken {
+					return errRUTTaken
+				}
+			}
+			if err := tx.Model(u).Update("rut", rutValue).Error; err != nil {
+				return err
+			}
+		}
+
+		if req.FullName != nil {
+			// La fila de contacto puede no existir (usuarios antiguos): se crea
+			contact := models.Contact{UserID: u.ID}
+			if err := tx.Where("user_id = ?", u.ID).FirstOrCreate(&contact).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&contact).Update("full_name", strings.TrimSpace(*req.FullName)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errUsernameTaken) || errors.Is(err, errRUTTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo actualizar el perfil"})
+		return
+	}
+
+	h.Me(c)
+}
+
+// UpdateMyAddress cambia la dirección por defecto. Como las direcciones se comparten entre usuarios,
+// no se edita la fila actual: se busca (o crea) la dirección exacta y se apunta a ella.
+// Se mantiene por compatibilidad; las direcciones etiquetadas se manejan en /me/addresses.
+func (h *UserHandler) UpdateMyAddress(c *gin.Context) {
+	var req AddressInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var addr models.Address
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		addr, err = findOrCreateAddress(tx, req)
+		if err != nil {
+			return err
+		}
+
+		// Reemplaza la dirección por defecto (o crea una "home" si el usuario no tiene ninguna)
+		var ua models.UserAddress
+		res := tx.Where("user_id = ? AND is_default = ?", u.ID, true).Limit(1).Find(&ua)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			ua = models.UserAddress{UserID: u.ID, Label: models.AddressLabelHome, IsDefault: true}
+		}
+		ua.AddressID = addr.ID
+		if err := tx.Save(&ua).Error; err != nil {
+			return err
+		}
+		return setDefaultAddress(tx, u.ID, ua.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errCommuneNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo actualizar la dirección"})
+		return
+	}
+
+	c.JSON(http.StatusOK, addressJSON(addr))
+}
+
+// MyPhones lista los teléfonos del usuario actual (el principal primero).
+func (h *UserHandler) MyPhones(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var phones []models.UserPhone
+	if err := h.DB.Where("user_id = ?", u.ID).
+		Order("is_main desc, id asc").
+		Find(&phones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudieron cargar los teléfonos"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(phones))
+	for _, p := range phones {
+		out = append(out, phoneJSON(p))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// AddMyPhone agrega un teléfono. El primero queda como principal; si viene is_main=true,
+// pasa a ser el principal y el anterior deja de serlo.
+func (h *UserHandler) AddMyPhone(c *gin.Context) {
+	var req phoneReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	p := models.UserPhone{
+		UserID: u.ID,
+		Label:  req.Label,
+		Number: strings.TrimSpace(req.Number),
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&p).Error; err != nil {
+			return err
+		}
+		if req.IsMain {
+			return setMainPhone(tx, u.ID, p.ID)
+		}
+		return ensureMainPhone(tx, u.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo guardar el teléfono"})
+		return
+	}
+
+	h.DB.First(&p, p.ID)
+	c.JSON(http.StatusCreated, phoneJSON(p))
+}
+
+// UpdateMyPhone edita un teléfono. is_main=true lo vuelve principal; para dejar de serlo
+// hay que marcar otro como principal (siempre hay exactamente uno).
+func (h *UserHandler) UpdateMyPhone(c *gin.Context) {
+	var req phoneReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var p models.UserPhone
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := findMyPhone(tx, u.ID, c.Param("id"), &p); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&p).Updates(map[string]any{
+			"label":  req.Label,
+			"number": strings.TrimSpace(req.Number),
+		}).Error; err != nil {
+			return err
+		}
+
+		if req.IsMain && !p.IsMain {
+			return setMainPhone(tx, u.ID, p.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errPhoneNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo guardar el teléfono"})
+		return
+	}
+
+	h.DB.First(&p, p.ID)
+	c.JSON(http.StatusOK, phoneJSON(p))
+}
+
+// DeleteMyPhone borra un teléfono. Si era el principal, el más antiguo que quede pasa a serlo.
+func (h *UserHandler) DeleteMyPhone(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var p models.UserPhone
+		if err := findMyPhone(tx, u.ID, c.Param("id"), &p); err != nil {
+			return err
+		}
+		if err := tx.Delete(&p).Error; err != nil {
+			return err
+		}
+		return ensureMainPhone(tx, u.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errPhoneNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo borrar el teléfono"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func findMyPhone(tx *gorm.DB, userID uint, rawID string, p *models.UserPhone) error {
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		return errPhoneNotFound
+	}
+
+	res := tx.Where("id = ? AND user_id = ?", id, userID).Limit(1).Find(p)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errPhoneNotFound
+	}
+	return nil
+}
+
+// setMainPhone deja phoneID como único teléfono principal del usuario.
+func setMainPhone(tx *gorm.DB, userID, phoneID uint) error {
+	if err := tx.Model(&models.UserPhone{}).
+		Where("user_id = ? AND id <> ? AND is_main = ?", userID, phoneID, true).
+		Update("is_main", false).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.UserPhone{}).
+		Where("id = ?", phoneID).
+		Update("is_main", true).Error
+}
+
+// ensureMainPhone garantiza exactamente un principal si el usuario tiene teléfonos
+// (si no hay ninguno, el más antiguo; si hay varios, se queda el más antiguo de ellos).
+func ensureMainPhone(tx *gorm.DB, userID uint) error {
+	var phones []models.UserPhone
+	if err := tx.Where("user_id = ?", userID).Order("id asc").Find(&phones).Error; err != nil {
+		return err
+	}
+	if len(phones) == 0 {
+		return nil
+	}
+
+	mainID := phones[0].ID
+	for _, p := range phones {
+		if p.IsMain {
+			mainID = p.ID
+			break
+		}
+	}
+	return setMainPhone(tx, userID, mainID)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var errUserTokenInvalid = errors.New("token inválido o expirado")
+
+// createUserToken invalida los tokens pendientes del mismo propósito y crea uno nuevo.
+// Devuelve el token plano (para enviar por correo).
+func createUserToken(tx *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	if err := tx.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+
+	plain, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	t := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := tx.Create(&t).Error; err != nil {
+		return "", err
+	}
+
+	return plain, nil
+}
+
+// consumeUserToken marca el token como usado (una sola vez) y lo devuelve.
+// Debe llamarse dentro de una transacción junto al cambio que autoriza.
+func consumeUserToken(tx *gorm.DB, plain, purpose string) (*models.UserToken, error) {
+	var t models.UserToken
+	err := tx.Where("token_hash = ? AND purpose = ?", auth.HashOpaqueToken(plain), purpose).
+		First(&t).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errUserTokenInvalid
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if t.UsedAt != nil || now.After(t.ExpiresAt) {
+		return nil, errUserTokenInvalid
+	}
+
+	res := tx.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", t.ID).
+		Update("used_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errUserTokenInvalid
+	}
+
+	return &t, nil
+}
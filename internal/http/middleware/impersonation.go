@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"handsoft/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BlockImpersonation rechaza la ruta si el token es de impersonación
+// (cambio de contraseña, MFA, administración, etc.). Debe ir después de AuthJWT.
+func BlockImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(CtxActorIDKey); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "acción no permitida durante impersonación"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AuditImpersonation registra en audit_logs cada request hecho con un token de impersonación.
+// Debe ir después de AuthJWT.
+func AuditImpersonation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorAny, ok := c.Get(CtxActorIDKey)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		actorID, _ := actorAny.(uint)
+		userID := c.GetUint(CtxUserIDKey)
+
+		entry := models.AuditLog{
+			Action:        "impersonation.request",
+			ActorID:       &actorID,
+			SubjectUserID: &userID,
+			Method:        c.Request.Method,
+			Path:          c.Request.URL.Path,
+			Status:        c.Writer.Status(),
+			IP:            c.ClientIP(),
+			UserAgent:     c.Request.UserAgent(),
+		}
+		if client, ok := c.Get(CtxAPIClientKey); ok {
+			if info, ok := client.(APIClientInfo); ok && info.ID != 0 {
+				entry.APIClientID = &info.ID
+			}
+		}
+
+		if err := db.Create(&entry).Error; err != nil {
+			log.Printf("audit: no se pudo registrar request impersonado (actor %d, user %d): %v", actorID, userID, err)
+		}
+	}
+}
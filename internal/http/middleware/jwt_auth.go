@@ -12,9 +12,19 @@ import (
 const (
 	CtxUserIDKey = "userID"
 	CtxRolesKey  = "roles"
+	CtxClaimsKey = "claims"
+
+	// Solo presente con tokens de impersonación: ID del super admin que actúa como el usuario
+	CtxActorIDKey = "actorID"
+
+	// auth.SubTypeUser o auth.SubTypeService. Con service account no se setea CtxUserIDKey.
+	CtxSubTypeKey          = "subType"
+	CtxServiceAccountIDKey = "serviceAccountID"
 )
 
-func AuthJWT(cfg auth.JWTConfig) gin.HandlerFunc {
+// AuthJWT valida el Bearer token. Si revocations no es nil, además rechaza tokens revocados
+// (logout, cerrar sesión en todos lados, revocación por admin).
+func AuthJWT(cfg auth.JWTConfig, revocations *auth.RevocationStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		h := c.GetHeader("Authorization")
 		if h == "" {
@@ -40,10 +50,44 @@ func AuthJWT(cfg auth.JWTConfig) gin.HandlerFunc {
 			return
 		}
 
+		if revocations != nil {
+			revoked, err := revocations.IsRevoked(claims)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "no se pudo validar el token"})
+				return
+			}
+			if revoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revocado"})
+				return
+			}
+		}
+
 		// Guardamos datos útiles para handlers
-		c.Set(CtxUserIDKey, claims.UserID)
+		if claims.IsService() {
+			c.Set(CtxSubTypeKey, auth.SubTypeService)
+			c.Set(CtxServiceAccountIDKey, claims.ServiceAccountID)
+		} else {
+			c.Set(CtxSubTypeKey, auth.SubTypeUser)
+			c.Set(CtxUserIDKey, claims.UserID)
+		}
 		c.Set(CtxRolesKey, claims.Roles)
+		c.Set(CtxClaimsKey, claims)
+		if claims.ActorID != 0 {
+			c.Set(CtxActorIDKey, claims.ActorID)
+		}
 
 		c.Next()
 	}
 }
+
+// RequireUserSubject rechaza tokens de service account en rutas que solo tienen sentido
+// para una persona (perfil, contraseña, MFA, administración). Debe ir después de AuthJWT.
+func RequireUserSubject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(CtxSubTypeKey) == auth.SubTypeService {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "no permitido para service accounts"})
+			return
+		}
+		c.Next()
+	}
+}
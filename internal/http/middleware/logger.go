@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestLogger es el logger de gin con la app (API client) y el usuario que hizo la llamada.
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		client := "-"
+		if v, ok := p.Keys[CtxAPIClientKey].(APIClientInfo); ok {
+			client = fmt.Sprintf("%s#%d", v.Name, v.ID)
+		}
+
+		user := "-"
+		if v, ok := p.Keys[CtxUserIDKey].(uint); ok {
+			user = fmt.Sprint(v)
+		} else if v, ok := p.Keys[CtxServiceAccountIDKey].(uint); ok {
+			user = fmt.Sprintf("sa:%d", v)
+		}
+
+		return fmt.Sprintf("[GIN] %s | %3d | %13v | %15s | client=%s user=%s | %-7s %#v\n%s",
+			p.TimeStamp.Format(time.RFC3339),
+			p.StatusCode,
+			p.Latency,
+			p.ClientIP,
+			client,
+			user,
+			p.Method,
+			p.Path,
+			p.ErrorMessage,
+		)
+	})
+}
@@ -1,35 +1,207 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
+
+	"handsoft/internal/auth"
+	"handsoft/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+const CtxAPIClientKey = "apiClient"
+
+// Scopes de API key (uno por grupo de rutas); APIScopeAll da acceso a todos.
+const APIScopeAll = "*"
+
+var KnownAPIScopes = []string{"auth", "users", "geo", "admin", "warehouse", "inventory"}
+
+// APIClientInfo es lo que queda en el contexto sobre la app que llama.
+type APIClientInfo struct {
+	ID     uint // 0 = key legacy de variable de entorno
+	Name   string
+	Scopes []string
+}
+
+func (a APIClientInfo) HasScope(scope string) bool {
+	for _, s := range a.Scopes {
+		if s == APIScopeAll || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 type APIKeyConfig struct {
-	
+	// Keys legacy (variable de entorno API_KEY). Tienen todos los scopes.
 	ValidKeys map[string]bool
 
+	// Keys por cliente en la tabla api_clients
+	DB *gorm.DB
+
+	// Tiempo que se cachea la resolución de una key (revocar tarda a lo más esto en aplicar)
+	CacheTTL time.Duration
 
 	HeaderName string
 }
 
+// Tope de keys en cache. Solo se cachean keys existentes, así que normalmente no se llega
+const apiKeyCacheMax = 1000
+
+type apiKeyCacheEntry struct {
+	client     *models.APIClient
+	cachedTill time.Time
+	lastTouch  time.Time
+}
+
 func RequireAPIKey(cfg APIKeyConfig) gin.HandlerFunc {
 	headerName := cfg.HeaderName
 	if headerName == "" {
 		headerName = "X-API-Key"
 	}
 
+	var (
+		mu    sync.Mutex
+		cache = map[string]*apiKeyCacheEntry{}
+	)
+
+	// resolve busca la key (por hash) usando el cache; actualiza last_used_at como mucho 1 vez por minuto
+	resolve := func(key string) (*models.APIClient, error) {
+		hash := auth.HashOpaqueToken(key)
+		now := time.Now()
+
+		mu.Lock()
+		e, ok := cache[hash]
+		mu.Unlock()
+
+		if !ok || now.After(e.cachedTill) {
+			var client models.APIClient
+			err := cfg.DB.Where("key_hash = ?", hash).First(&client).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Las keys inexistentes no se cachean: si no, keys al azar harían crecer el cache sin límite
+				mu.Lock()
+				delete(cache, hash)
+				mu.Unlock()
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			ne := &apiKeyCacheEntry{client: &client, cachedTill: now.Add(cfg.CacheTTL)}
+			if client.LastUsedAt != nil {
+				ne.lastTouch = *client.LastUsedAt
+			}
+
+			mu.Lock()
+			if len(cache) >= apiKeyCacheMax {
+				evictAPIKeys(cache, now)
+			}
+			cache[hash] = ne
+			mu.Unlock()
+			e = ne
+		}
+
+		mu.Lock()
+		touch := now.Sub(e.lastTouch) > time.Minute
+		if touch {
+			e.lastTouch = now
+		}
+		mu.Unlock()
+
+		if touch {
+			if err := cfg.DB.Model(&models.APIClient{}).
+				Where("id = ?", e.client.ID).
+				Update("last_used_at", now).Error; err != nil {
+				log.Printf("api key: no se pudo actualizar last_used_at de client %d: %v", e.client.ID, err)
+			}
+		}
+
+		return e.client, nil
+	}
+
 	return func(c *gin.Context) {
 		key := strings.TrimSpace(c.GetHeader(headerName))
-		if key == "" || !cfg.ValidKeys[key] {
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "missing_or_invalid_api_key",
+			})
+			return
+		}
+
+		if cfg.ValidKeys[key] {
+			c.Set(CtxAPIClientKey, APIClientInfo{Name: "legacy", Scopes: []string{APIScopeAll}})
+			c.Next()
+			return
+		}
+
+		if cfg.DB == nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "missing_or_invalid_api_key",
 			})
 			return
 		}
 
+		client, err := resolve(key)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error checking api key"})
+			return
+		}
+		if client == nil || !client.Usable(time.Now()) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "missing_or_invalid_api_key",
+			})
+			return
+		}
+
+		c.Set(CtxAPIClientKey, APIClientInfo{
+			ID:     client.ID,
+			Name:   client.Name,
+			Scopes: client.ScopeList(),
+		})
+
+		c.Next()
+	}
+}
+
+// RequireAPIScope exige que la API key del request tenga el scope (o "*").
+// Debe ir después de RequireAPIKey.
+func RequireAPIScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get(CtxAPIClientKey)
+		client, _ := v.(APIClientInfo)
+		if !ok || !client.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "api_key_scope_required",
+				"scope": scope,
+			})
+			return
+		}
+
 		c.Next()
 	}
 }
+
+// evictAPIKeys libera espacio en el cache: borra las entradas vencidas y, si no alcanza,
+// la que vence primero.
+func evictAPIKeys(cache map[string]*apiKeyCacheEntry, now time.Time) {
+	oldest := ""
+	for k, e := range cache {
+		if now.After(e.cachedTill) {
+			delete(cache, k)
+			continue
+		}
+		if oldest == "" || e.cachedTill.Before(cache[oldest].cachedTill) {
+			oldest = k
+		}
+	}
+	if len(cache) >= apiKeyCacheMax && oldest != "" {
+		delete(cache, oldest)
+	}
+}
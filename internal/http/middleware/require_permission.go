@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"handsoft/internal/models"
+	"handsoft/internal/permissions"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -13,7 +14,13 @@ import (
 // RequirePermission valida que el usuario (según roles en el JWT) tenga un permiso.
 // - Bypass total si alguno de sus roles tiene IsSuperAdmin=true.
 // - Soporta comodín "modulo:*" además del permiso exacto.
+// - Cuenta los permisos heredados de los roles padre (jerarquía de roles).
+// El código debe estar declarado en internal/permissions; si no, falla al registrar la ruta.
 func RequirePermission(db *gorm.DB, permissionCode string) gin.HandlerFunc {
+	if !permissions.Known(permissionCode) {
+		panic("RequirePermission: permiso no declarado en internal/permissions: " + permissionCode)
+	}
+
 	return func(c *gin.Context) {
 
 		rolesAny, ok := c.Get(CtxRolesKey)
@@ -88,13 +95,7 @@ func hasSuperAdminRole(db *gorm.DB, roleNames []string) (bool, error) {
 	return count > 0, err
 }
 
+// roleHasPermission incluye los permisos heredados de los roles padre.
 func roleHasPermission(db *gorm.DB, roleNames []string, permissionCode string) (bool, error) {
-	var count int64
-	err := db.Model(&models.Permission{}).
-		Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
-		Joins("JOIN roles r ON r.id = rp.role_id").
-		Where("r.name IN ?", roleNames).
-		Where("permissions.code = ?", permissionCode).
-		Count(&count).Error
-	return count > 0, err
+	return permissions.Granted(db, roleNames, permissionCode)
 }
@@ -1,25 +1,71 @@
 package routes
 
 import (
-	"handsoft/internal/auth"
+	"time"
+
 	"handsoft/internal/http/handlers"
 	"handsoft/internal/http/middleware"
+	"handsoft/internal/permissions"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterAdminRoutes(api *gin.RouterGroup, deps Deps) {
-	jwtCfg := auth.JWTConfig{
-		Secret:    deps.JWTSecret,
-		Issuer:    deps.Issuer,
-		AccessTTL: deps.AccessTTL,
+	jwtCfg := deps.jwtConfig()
+
+	adminH := &handlers.AdminHandler{
+		DB:          deps.DB,
+		JWTConfig:   jwtCfg,
+		Revocations: deps.Revocations,
+
+		Mailer:           deps.Mailer,
+		AppURL:           deps.AppURL,
+		InvitationTTL:    deps.InvitationTTL,
+		PasswordResetTTL: 30 * time.Minute,
+
+		Hasher:  deps.PasswordHasher,
+		Storage: deps.Storage,
 	}
 
-	adminH := &handlers.AdminHandler{DB: deps.DB}
+	// Gestión de usuarios: por permisos (users:read, users:create, users:update, users:delete, users:roles), no solo super admin
+	users := api.Group("/admin/users")
+	users.Use(
+		middleware.RequireAPIScope("admin"),
+		middleware.AuthJWT(jwtCfg, deps.Revocations),
+		middleware.AuditImpersonation(deps.DB),
+		middleware.BlockImpersonation(),
+		middleware.RequireUserSubject(),
+	)
+	{
+		users.GET("", middleware.RequirePermission(deps.DB, permissions.UsersRead), adminH.ListUsers)
+		users.GET("/export", middleware.RequirePermission(deps.DB, permissions.UsersRead), adminH.ExportUsers)
+		users.POST("/import",
+			middleware.RequirePermission(deps.DB, permissions.UsersCreate),
+			middleware.RequirePermission(deps.DB, permissions.UsersRoles),
+			adminH.ImportUsers,
+		)
+		users.GET("/:id", middleware.RequirePermission(deps.DB, permissions.UsersRead), adminH.GetUser)
+		users.PATCH("/:id", middleware.RequirePermission(deps.DB, permissions.UsersUpdate), adminH.UpdateUser)
+		users.POST("/:id/password-reset", middleware.RequirePermission(deps.DB, permissions.UsersUpdate), adminH.ForcePasswordReset)
+		users.DELETE("/:id", middleware.RequirePermission(deps.DB, permissions.UsersDelete), adminH.DeleteUser)
+
+		// Datos personales: export y anonimización (irreversible; incluye usuarios borrados)
+		users.GET("/:id/data-export", middleware.RequirePermission(deps.DB, permissions.UsersRead), adminH.ExportUserData)
+		users.POST("/:id/anonymize", middleware.RequirePermission(deps.DB, permissions.UsersDelete), adminH.AnonymizeUser)
+
+		// Roles del usuario (dar/quitar roles super admin exige ser super admin)
+		users.PUT("/:id/roles", middleware.RequirePermission(deps.DB, permissions.UsersRoles), adminH.SetUserRoles)
+		users.POST("/:id/roles", middleware.RequirePermission(deps.DB, permissions.UsersRoles), adminH.AddUserRoles)
+		users.DELETE("/:id/roles/:role", middleware.RequirePermission(deps.DB, permissions.UsersRoles), adminH.RemoveUserRole)
+	}
 
 	admin := api.Group("/admin")
 	admin.Use(
-		middleware.AuthJWT(jwtCfg),
+		middleware.RequireAPIScope("admin"),
+		middleware.AuthJWT(jwtCfg, deps.Revocations),
+		middleware.AuditImpersonation(deps.DB),
+		middleware.BlockImpersonation(),
+		middleware.RequireUserSubject(),
 		middleware.RequireSuperAdmin(deps.DB),
 	)
 
@@ -29,10 +75,44 @@ func RegisterAdminRoutes(api *gin.RouterGroup, deps Deps) {
 	admin.PUT("/roles/:id", adminH.UpdateRole)
 	admin.DELETE("/roles/:id", adminH.DeleteRole)
 
+	// Jerarquía: el rol hereda los permisos del padre (parent_id null = sin padre)
+	admin.PUT("/roles/:id/parent", adminH.SetRoleParent)
+
 	// Permissions
 	admin.GET("/permissions", adminH.ListPermissions)
 
 	// Asignar permisos a un rol (replace)
 	admin.PUT("/roles/:id/permissions", adminH.SetRolePermissions)
 	admin.GET("/roles/:id/permissions", adminH.GetRolePermissions)
+
+	// Usuarios: sesiones (listar, cerrar una, cerrar todas)
+	admin.POST("/users/:id/revoke-tokens", adminH.RevokeUserTokens)
+	admin.GET("/users/:id/sessions", adminH.ListUserSessions)
+	admin.DELETE("/users/:id/sessions/:sessionId", adminH.RevokeUserSession)
+
+	// Fuerza bruta: desbloqueo e historial de intentos
+	admin.POST("/users/:id/unlock", adminH.UnlockUser)
+	admin.GET("/login-attempts", adminH.ListLoginAttempts)
+
+	// Impersonación (soporte) y auditoría
+	admin.POST("/users/:id/impersonate", adminH.Impersonate)
+	admin.GET("/audit-logs", adminH.ListAuditLogs)
+
+	// Invitaciones
+	admin.GET("/invitations", adminH.ListInvitations)
+	admin.POST("/invitations", adminH.CreateInvitation)
+	admin.POST("/invitations/:id/resend", adminH.ResendInvitation)
+	admin.DELETE("/invitations/:id", adminH.RevokeInvitation)
+
+	// API keys por cliente
+	admin.GET("/api-clients", adminH.ListAPIClients)
+	admin.POST("/api-clients", adminH.CreateAPIClient)
+	admin.DELETE("/api-clients/:id", adminH.RevokeAPIClient)
+
+	// Service accounts (client credentials)
+	admin.GET("/service-accounts", adminH.ListServiceAccounts)
+	admin.POST("/service-accounts", adminH.CreateServiceAccount)
+	admin.PUT("/service-accounts/:id/roles", adminH.SetServiceAccountRoles)
+	admin.POST("/service-accounts/:id/secret", adminH.RotateServiceAccountSecret)
+	admin.DELETE("/service-accounts/:id", adminH.DisableServiceAccount)
 }
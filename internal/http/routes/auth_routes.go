@@ -1,25 +1,73 @@
 package routes
 
 import (
-	"handsoft/internal/auth"
+	"time"
+
 	"handsoft/internal/http/handlers"
+	"handsoft/internal/http/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterAuthRoutes(api *gin.RouterGroup, deps Deps) {
+	jwtCfg := deps.jwtConfig()
+
 	authHandler := &handlers.AuthHandler{
-		DB: deps.DB,
-		JWTConfig: auth.JWTConfig{
-			Secret:    deps.JWTSecret,
-			Issuer:    deps.Issuer,
-			AccessTTL: deps.AccessTTL,
-		},
+		DB:          deps.DB,
+		JWTConfig:   jwtCfg,
+		RefreshTTL:  deps.RefreshTTL,
+		Revocations: deps.Revocations,
+
+		Mailer:           deps.Mailer,
+		AppURL:           deps.AppURL,
+		PasswordResetTTL: 30 * time.Minute,
+
+		EmailVerification: deps.EmailVerification,
+		LoginProtection:   deps.LoginProtection,
+		PasswordPolicy:    deps.PasswordPolicy,
+		Hasher:            deps.PasswordHasher,
+
+		DisablePublicRegistration: deps.DisablePublicRegistration,
+
+		OIDC: deps.OIDC,
 	}
 
+	requireJWT := middleware.AuthJWT(jwtCfg, deps.Revocations)
+	audit := middleware.AuditImpersonation(deps.DB)
+
 	authRoutes := api.Group("/auth")
+	authRoutes.Use(middleware.RequireAPIScope("auth"))
 	{
 		authRoutes.POST("/register", authHandler.Register)
 		authRoutes.POST("/login", authHandler.Login)
+		authRoutes.POST("/login/mfa", authHandler.LoginMFA)
+		authRoutes.POST("/mfa/enroll", authHandler.MFAEnroll)
+		authRoutes.POST("/mfa/enroll/confirm", authHandler.MFAEnrollConfirm)
+		authRoutes.POST("/refresh", authHandler.Refresh)
+
+		// OAuth2 client credentials (service accounts)
+		authRoutes.POST("/token", authHandler.Token)
+
+		authRoutes.POST("/logout", requireJWT, audit, authHandler.Logout)
+		authRoutes.POST("/logout-all", requireJWT, audit, middleware.RequireUserSubject(), middleware.BlockImpersonation(), authHandler.LogoutAll)
+
+		authRoutes.POST("/password/forgot", authHandler.ForgotPassword)
+		authRoutes.POST("/password/reset", authHandler.ResetPassword)
+
+		authRoutes.POST("/verify-email", authHandler.VerifyEmail)
+		authRoutes.POST("/verify-email/resend", authHandler.ResendVerification)
+
+		// Invitaciones (alta de cuentas invitadas por un admin)
+		authRoutes.POST("/invitations/inspect", authHandler.InspectInvitation)
+		authRoutes.POST("/invitations/accept", authHandler.AcceptInvitation)
+
+		// SSO (OIDC authorization code + PKCE)
+		authRoutes.GET("/oidc/providers", authHandler.OIDCProviders)
+		authRoutes.GET("/oidc/:provider/authorize", authHandler.OIDCAuthorize)
+		authRoutes.POST("/oidc/:provider/callback", authHandler.OIDCCallback)
+
+		// Vincular una cuenta del IdP al usuario de la sesión (si el login por SSO respondió link_required)
+		authRoutes.GET("/oidc/:provider/link/authorize", requireJWT, audit, middleware.RequireUserSubject(), middleware.BlockImpersonation(), authHandler.OIDCLinkAuthorize)
+		authRoutes.POST("/oidc/:provider/link", requireJWT, audit, middleware.RequireUserSubject(), middleware.BlockImpersonation(), authHandler.OIDCLinkCallback)
 	}
 }
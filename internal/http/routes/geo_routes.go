@@ -2,6 +2,7 @@ package routes
 
 import (
 	"handsoft/internal/http/handlers"
+	"handsoft/internal/http/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,6 +11,7 @@ func RegisterGeoRoutes(api *gin.RouterGroup, deps Deps) {
 	h := &handlers.GeoHandler{DB: deps.DB}
 
 	geo := api.Group("/geo")
+	geo.Use(middleware.RequireAPIScope("geo"))
 	{
 		geo.GET("/regions", h.Regions)
 		geo.GET("/regions/:regionId/cities", h.CitiesByRegion)
@@ -4,7 +4,13 @@ import (
 	"os"
 	"time"
 
+	"handsoft/internal/auth"
+	"handsoft/internal/http/handlers"
 	"handsoft/internal/http/middleware"
+	"handsoft/internal/mail"
+	"handsoft/internal/oidc"
+	"handsoft/internal/rut"
+	"handsoft/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -16,26 +22,80 @@ type Deps struct {
 	JWTSecret string
 	Issuer    string
 	AccessTTL time.Duration
+
+	// Llaves asimétricas (RS256/EdDSA). nil = HS256 con JWTSecret
+	Keys *auth.KeySet
+
+	// Vida del refresh token (rotado en cada uso)
+	RefreshTTL time.Duration
+
+	// Revocación de access tokens (compartida por todas las rutas con JWT)
+	Revocations *auth.RevocationStore
+
+	// Envío de correos y URL del frontend (para links en los correos)
+	Mailer mail.Sender
+	AppURL string
+
+	// Invitaciones: vigencia del link y si POST /auth/register queda cerrado
+	InvitationTTL             time.Duration
+	DisablePublicRegistration bool
+
+	// Qué hacer en login con cuentas sin email verificado
+	EmailVerification handlers.EmailVerificationPolicy
+
+	// Protección contra fuerza bruta en login
+	LoginProtection handlers.LoginProtection
+
+	// Reglas para contraseñas nuevas (registro, cambio, reset)
+	PasswordPolicy auth.PasswordPolicy
+
+	// Algoritmo y parámetros de hash de contraseñas
+	PasswordHasher auth.PasswordHasher
+
+	// Proveedores OIDC para SSO (clave = nombre en la URL)
+	OIDC map[string]*oidc.Provider
+
+	// Archivos subidos (fotos de perfil). nil = subida deshabilitada
+	Storage storage.Store
+}
+
+// jwtConfig arma la configuración JWT común a todas las rutas
+func (d Deps) jwtConfig() auth.JWTConfig {
+	return auth.JWTConfig{
+		Secret:    d.JWTSecret,
+		Issuer:    d.Issuer,
+		AccessTTL: d.AccessTTL,
+		Keys:      d.Keys,
+	}
 }
 
 // Register es el punto único de entrada de rutas
 func Register(r *gin.Engine, deps Deps) {
+	// Reglas de validación propias para binding (ej: `binding:"rut"`)
+	rut.RegisterBindingValidator()
+
+	// ============================
+	// JWKS (público, sin API key): otros servicios validan nuestros tokens localmente
+	// ============================
+	jwksH := &handlers.JWKSHandler{Keys: deps.Keys}
+	r.GET("/.well-known/jwks.json", jwksH.JWKS)
 
 	// ============================
 	// API KEY (obligatoria)
 	// ============================
-	apiKey := os.Getenv("API_KEY")
-	if apiKey == "" {
-		panic("API_KEY no está definido en variables de entorno")
+	// Cada app tiene su key en api_clients. API_KEY (opcional) se mantiene como key legacy con todos los scopes.
+	legacyKeys := map[string]bool{}
+	if apiKey := os.Getenv("API_KEY"); apiKey != "" {
+		legacyKeys[apiKey] = true
 	}
 
 	api := r.Group("/api")
 
-	// 🔐 Middleware API-Key global para /api
+	// 🔐 Middleware API-Key global para /api (resuelve el API client en el contexto)
 	api.Use(middleware.RequireAPIKey(middleware.APIKeyConfig{
-		ValidKeys: map[string]bool{
-			apiKey: true,
-		},
+		ValidKeys:  legacyKeys,
+		DB:         deps.DB,
+		CacheTTL:   30 * time.Second,
 		HeaderName: "X-API-Key",
 	}))
 
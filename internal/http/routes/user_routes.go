@@ -1,7 +1,6 @@
 package routes
 
 import (
-	"handsoft/internal/auth"
 	"handsoft/internal/http/handlers"
 	"handsoft/internal/http/middleware"
 
@@ -9,17 +8,60 @@ import (
 )
 
 func RegisterUserRoutes(api *gin.RouterGroup, deps Deps) {
-	jwtCfg := auth.JWTConfig{
-		Secret:    deps.JWTSecret,
-		Issuer:    deps.Issuer,
-		AccessTTL: deps.AccessTTL,
+	jwtCfg := deps.jwtConfig()
+
+	userH := &handlers.UserHandler{
+		DB:             deps.DB,
+		Revocations:    deps.Revocations,
+		PasswordPolicy: deps.PasswordPolicy,
+		Hasher:         deps.PasswordHasher,
+		Storage:        deps.Storage,
 	}
 
-	userH := &handlers.UserHandler{DB: deps.DB}
+	// Acciones sensibles: no se permiten con token de impersonación
+	sensitive := middleware.BlockImpersonation()
+
+	// Todo lo de /me (salvo el perfil) es de personas, no de service accounts
+	human := middleware.RequireUserSubject()
 
 	users := api.Group("/users")
-	users.Use(middleware.AuthJWT(jwtCfg))
+	users.Use(
+		middleware.RequireAPIScope("users"),
+		middleware.AuthJWT(jwtCfg, deps.Revocations),
+		middleware.AuditImpersonation(deps.DB),
+	)
 	{
 		users.GET("/me", userH.Me)
+
+		// Perfil propio (nombre, username, foto, direcciones y teléfonos).
+		// Editarlo es sensible: el username es identificador de login y el resto son datos personales
+		users.PATCH("/me", human, sensitive, userH.UpdateMe)
+		users.PUT("/me/avatar", human, sensitive, userH.UploadMyAvatar)
+		users.DELETE("/me/avatar", human, sensitive, userH.DeleteMyAvatar)
+		users.PUT("/me/address", human, sensitive, userH.UpdateMyAddress)
+		users.GET("/me/addresses", human, userH.MyAddresses)
+		users.POST("/me/addresses", human, sensitive, userH.AddMyAddress)
+		users.PUT("/me/addresses/:id", human, sensitive, userH.UpdateMyAddressByID)
+		users.DELETE("/me/addresses/:id", human, sensitive, userH.DeleteMyAddress)
+		users.GET("/me/phones", human, userH.MyPhones)
+		users.POST("/me/phones", human, sensitive, userH.AddMyPhone)
+		users.PUT("/me/phones/:id", human, sensitive, userH.UpdateMyPhone)
+		users.DELETE("/me/phones/:id", human, sensitive, userH.DeleteMyPhone)
+
+		users.PUT("/me/password", human, sensitive, userH.ChangePassword)
+
+		// Export de datos personales (ZIP, o ?format=json)
+		users.GET("/me/data-export", human, sensitive, userH.ExportMyData)
+
+		// Sesiones activas (dispositivos)
+		users.GET("/me/sessions", human, userH.MySessions)
+		users.DELETE("/me/sessions/:id", human, sensitive, userH.RevokeMySession)
+
+		// MFA (TOTP)
+		users.GET("/me/mfa", human, userH.MFAStatus)
+		users.POST("/me/mfa/totp", human, sensitive, userH.EnrollTOTP)
+		users.POST("/me/mfa/totp/confirm", human, sensitive, userH.ConfirmTOTP)
+		users.DELETE("/me/mfa/totp", human, sensitive, userH.DisableTOTP)
+		users.POST("/me/mfa/recovery-codes", human, sensitive, userH.RegenerateRecoveryCodes)
 	}
 }
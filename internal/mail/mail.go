@@ -0,0 +1,16 @@
+package mail
+
+import "context"
+
+// Message es un correo simple (texto plano, opcionalmente HTML).
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender envía correos. Implementaciones: SMTPSender (producción) y OutboxSender (dev/tests).
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// OutboxSender no envía nada: escribe cada correo como archivo .eml en Dir.
+// Útil en desarrollo y tests para leer links de verificación/reset.
+type OutboxSender struct {
+	Dir  string
+	From string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func (s *OutboxSender) Send(_ context.Context, msg Message) error {
+	body, err := buildMIME(s.From, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.seq++
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().Format("20060102T150405"), s.seq)
+	s.mu.Unlock()
+
+	return os.WriteFile(filepath.Join(s.Dir, name), body, 0o644)
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIClient es una aplicación que consume la API con su propia API key.
+// Solo se guarda el hash de la key; KeyPrefix permite reconocerla en listados.
+type APIClient struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Name      string `gorm:"not null"`
+	KeyPrefix string `gorm:"index;not null"`
+	KeyHash   string `gorm:"uniqueIndex;not null"`
+
+	// Scopes separados por coma (ej: "auth,users,geo"); "*" = todos
+	Scopes string `gorm:"not null;default:''"`
+
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (c APIClient) ScopeList() []string {
+	out := []string{}
+	for _, s := range strings.Split(c.Scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Usable indica si la key no está revocada ni expirada.
+func (c APIClient) Usable(now time.Time) bool {
+	if c.RevokedAt != nil {
+		return false
+	}
+	return c.ExpiresAt == nil || now.Before(*c.ExpiresAt)
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// AuditLog registra acciones sensibles (impersonación, etc.) con quién las hizo y desde dónde.
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	Action string `gorm:"index;not null"` // impersonation.start, impersonation.request, ...
+
+	// Quién actuó realmente y sobre quién (en impersonación: el admin y el usuario impersonado)
+	ActorID       *uint `gorm:"index"`
+	SubjectUserID *uint `gorm:"index"`
+
+	// App que hizo la llamada (nil = key legacy)
+	APIClientID *uint
+
+	Method    string
+	Path      string
+	Status    int
+	IP        string
+	UserAgent string
+	Detail    string
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// DataMigration registra los backfills de una sola vez ya aplicados (ver db.RunOnce).
+type DataMigration struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"uniqueIndex;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
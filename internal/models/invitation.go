@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Invitation es una invitación enviada por un admin para crear una cuenta con roles ya asignados
+// (y opcionalmente acotada a un espacio o bodega). Solo se guarda el hash del token.
+type Invitation struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Email     string `gorm:"index;not null"`
+	TokenHash string `gorm:"uniqueIndex;not null"`
+
+	Roles []Role `gorm:"many2many:invitation_roles;"`
+
+	// Alcance opcional que se copia al usuario al aceptar
+	SpaceID     *uint
+	Space       *Space `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	WarehouseID *uint
+	Warehouse   *Warehouse `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	InvitedByID *uint
+	InvitedBy   *User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	ExpiresAt      time.Time `gorm:"not null"`
+	AcceptedAt     *time.Time
+	AcceptedUserID *uint
+	RevokedAt      *time.Time
+}
+
+// Pending indica si la invitación todavía se puede aceptar.
+func (i Invitation) Pending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// UserScope acota a un usuario a un espacio o a una bodega (asignado al aceptar una invitación).
+type UserScope struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	UserID uint `gorm:"index;not null"`
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	SpaceID     *uint
+	Space       *Space `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	WarehouseID *uint
+	Warehouse   *Warehouse `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LoginAttempt es el historial de intentos de login (exitosos y fallidos).
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	Login  string `gorm:"index"` // lo que se escribió (email o username)
+	UserID *uint  `gorm:"index"` // nil si el login no corresponde a un usuario
+	User   *User  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	IP        string `gorm:"index"`
+	UserAgent string
+
+	Success bool
+	Reason  string // bad_password, unknown_user, locked, throttled, inactive, mfa_invalid, ...
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// MFARecoveryCode es un código de un solo uso para entrar si se pierde el autenticador.
+type MFARecoveryCode struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	UserID uint `gorm:"index;not null"`
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	CodeHash string `gorm:"index;not null"`
+	UsedAt   *time.Time
+}
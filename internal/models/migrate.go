@@ -8,7 +8,17 @@ func Models() []any {
 
 		// Dirección / usuarios
 		&Address{},
-		&User{}, &Contact{}, &UserPhone{},
+		&User{}, &Contact{}, &UserPhone{}, &UserAddress{}, &PasswordHistory{},
+
+		// Sesiones
+		&UserSession{}, &RefreshToken{}, &RevokedToken{},
+		&UserToken{}, &MFARecoveryCode{},
+		&LoginAttempt{},
+		&SigningKey{}, &APIClient{},
+		&AuditLog{},
+		&UserIdentity{}, &OIDCLoginState{},
+		&ServiceAccount{},
+		&DataMigration{},
 
 		// RBAC
 		&Role{}, &Permission{},
@@ -18,5 +28,8 @@ func Models() []any {
 		&SpaceFloor{},
 		&Warehouse{},
 		&WarehouseRack{},
+
+		// Invitaciones (después de bodegas por el alcance opcional)
+		&Invitation{}, &UserScope{},
 	}
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PasswordHistory guarda hashes de contraseñas anteriores para impedir su reutilización.
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	UserID uint `gorm:"index;not null"`
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	PasswordHash string `gorm:"not null"`
+}
@@ -12,6 +12,13 @@ type Role struct {
 
 	IsSuperAdmin bool `gorm:"default:false"`
 
+	// Usuarios con este rol no obtienen access token sin segundo factor (TOTP)
+	RequiresMFA bool `gorm:"default:false"`
+
+	// Rol padre: se heredan sus permisos (y los de sus ancestros). No hereda IsSuperAdmin ni RequiresMFA
+	ParentID *uint `gorm:"index"`
+	Parent   *Role `gorm:"constraint:OnDelete:SET NULL;"`
+
 	Permissions []Permission `gorm:"many2many:role_permissions;"`
 }
 
@@ -21,5 +28,6 @@ type Permission struct {
 	UpdatedAt time.Time
 
 	Code        string `gorm:"uniqueIndex;not null"`
+	Module      string `gorm:"index"` // agrupación para la UI ("users", "warehouse")
 	Description string
 }
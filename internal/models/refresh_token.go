@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// RefreshToken guarda el hash de un refresh token opaco.
+// Cada login abre una familia (FamilyID); cada uso rota el token dentro de la
+// misma familia. Si un token ya usado se vuelve a presentar, se revoca la familia completa.
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID uint `gorm:"index;not null"`
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	TokenHash string `gorm:"uniqueIndex;not null"` // sha256 (hex) del token, nunca el token plano
+	FamilyID  string `gorm:"index;not null"`
+
+	// Datos del dispositivo que inició la familia
+	DeviceID  string
+	UserAgent string
+	IP        string
+
+	ExpiresAt time.Time `gorm:"index;not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+
+	// Token que lo reemplazó al rotar
+	ReplacedByID *uint
+}
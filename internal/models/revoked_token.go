@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// RevokedToken registra access tokens (por jti) invalidados antes de su expiración.
+// Se puede purgar cuando ExpiresAt ya pasó: el token expiró de todas formas.
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	JTI       string    `gorm:"uniqueIndex;not null"`
+	UserID    uint      `gorm:"index;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	Reason    string // logout, admin, ...
+}
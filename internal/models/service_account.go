@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ServiceAccount es una cuenta máquina a máquina (ERP, impresoras de etiquetas, etc.).
+// No es un login de usuario: obtiene access tokens con client credentials (client_id + secret).
+// Solo se guarda el hash del secret.
+type ServiceAccount struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+
+	ClientID   string `gorm:"uniqueIndex;not null"`
+	SecretHash string `gorm:"not null"`
+
+	IsActive bool `gorm:"default:true"`
+
+	// Roles evaluados por RequirePermission igual que los de un usuario
+	Roles []Role `gorm:"many2many:service_account_roles;"`
+
+	// Access tokens emitidos antes de esta fecha se consideran revocados
+	TokensValidAfter *time.Time
+
+	LastUsedAt  *time.Time
+	CreatedByID *uint
+}
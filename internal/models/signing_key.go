@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SigningKey es una llave de firma de JWT (RS256 / EdDSA).
+// La llave vigente es la más nueva con ActivatesAt <= ahora; las anteriores siguen
+// publicadas en el JWKS hasta ExpiresAt para validar tokens ya emitidos.
+type SigningKey struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	Kid string `gorm:"uniqueIndex;not null"`
+	Alg string `gorm:"type:varchar(10);not null"`
+
+	// PKCS#8 en PEM. Quien tenga acceso a esta tabla puede firmar tokens: protegerla como un secreto.
+	PrivateKeyPEM string `gorm:"type:text;not null"`
+
+	ActivatesAt time.Time `gorm:"index;not null"`
+	ExpiresAt   time.Time `gorm:"index;not null"`
+}
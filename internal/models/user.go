@@ -1,25 +1,64 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	ID        uint      `gorm:"primaryKey"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
 
+	// Borrado lógico (admin). Email y username siguen reservados.
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	// Datos personales borrados (email/username reemplazados, sin contacto ni teléfonos). Es irreversible.
+	AnonymizedAt *time.Time
+
 	Email        string `gorm:"uniqueIndex;not null"`
 	Username     string `gorm:"uniqueIndex;not null"`
 	PasswordHash string `gorm:"not null"`
 	IsActive     bool   `gorm:"default:true"`
 
+	// RUT chileno en forma canónica ("12345678-5", ver internal/rut). nil = no informado
+	RUT *string `gorm:"uniqueIndex"`
+
+	// Foto de perfil: prefijo de las miniaturas en el storage ("avatars/12/<random>", ver internal/avatar). "" = sin foto
+	AvatarKey string
+
+	// Contraseñas anteriores (para la política de no reutilización)
+	PasswordHistory []PasswordHistory `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// nil = email aún no verificado
+	EmailVerifiedAt *time.Time
+
+	// MFA (TOTP). Secret se guarda al iniciar el enrolamiento; queda activo al confirmar (TOTPEnabledAt).
+	TOTPSecret    string
+	TOTPEnabledAt *time.Time
+	TOTPLastStep  int64 // último paso usado, evita reusar el mismo código
+
+	// Protección contra fuerza bruta
+	FailedLoginCount  int `gorm:"not null;default:0"`
+	LastFailedLoginAt *time.Time
+	LockedUntil       *time.Time
+
+	// Access tokens emitidos antes de esta fecha se consideran revocados ("cerrar sesión en todos lados")
+	TokensValidAfter *time.Time
+
 	// Ubicación administrativa (opcional, puede convivir con Address)
 	CommuneID *uint
 	Commune   *Commune `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 
-	// Dirección principal (muchos usuarios pueden compartir la misma Address)
+	// Dirección principal (muchos usuarios pueden compartir la misma Address).
+	// Es la dirección por defecto de Addresses; se mantiene por compatibilidad.
 	AddressID *uint
 	Address   *Address `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 
+	// Direcciones etiquetadas (casa, facturación, despacho, trabajo)
+	Addresses []UserAddress `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
 	// Contacto y teléfonos
 	Contacts Contact     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 	Phones   []UserPhone `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Etiquetas de dirección de usuario
+const (
+	AddressLabelHome     = "home"
+	AddressLabelBilling  = "billing"
+	AddressLabelDelivery = "delivery"
+	AddressLabelWork     = "work"
+)
+
+// UserAddress asocia una Address (compartida, ver findOrCreateAddress) a un usuario con una etiqueta.
+// La dirección por defecto se refleja en User.AddressID para compatibilidad.
+type UserAddress struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID uint `gorm:"index;not null"`
+
+	AddressID uint     `gorm:"index;not null"`
+	Address   *Address `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+
+	Label     string `gorm:"not null"` // home, billing, delivery, work
+	IsDefault bool   `gorm:"default:false"`
+}
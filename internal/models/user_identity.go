@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// UserIdentity vincula un usuario local con una cuenta de un proveedor OIDC (issuer + sub).
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID uint `gorm:"index;not null"`
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	Provider string `gorm:"uniqueIndex:idx_identity_provider_subject;not null"`
+	Subject  string `gorm:"uniqueIndex:idx_identity_provider_subject;not null"`
+
+	Email       string // último email informado por el IdP
+	LastLoginAt *time.Time
+}
+
+// OIDCLoginState guarda state, nonce y code_verifier entre el authorize y el callback. Es de un solo uso.
+type OIDCLoginState struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	State        string    `gorm:"uniqueIndex;not null"`
+	Provider     string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"index;not null"`
+
+	// Usuario (ya autenticado) al que se vinculará la identidad; nil = flujo de login
+	LinkUserID *uint
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// UserSession es un dispositivo con sesión iniciada. Corresponde a una familia de refresh tokens
+// (se crea en el login y se actualiza en cada refresh).
+type UserSession struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID uint `gorm:"index;not null"`
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	FamilyID string `gorm:"uniqueIndex;not null"`
+
+	DeviceID  string
+	UserAgent string
+	IP        string
+
+	LastSeenAt time.Time  `gorm:"not null"`
+	ExpiresAt  time.Time  `gorm:"index;not null"` // expiración del último refresh token emitido
+	RevokedAt  *time.Time `gorm:"index"`
+}
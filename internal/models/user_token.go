@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Propósitos de UserToken
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// UserToken es un token de un solo uso enviado por correo (reset de contraseña, verificación de email, etc.).
+// Solo se guarda el hash.
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID uint `gorm:"index;not null"`
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	Purpose   string    `gorm:"type:varchar(40);index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
@@ -0,0 +1,267 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config de un proveedor OIDC (authorization code + PKCE).
+type Config struct {
+	Name         string // identificador en la URL: /api/auth/oidc/:provider
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string // vacío para clientes públicos (solo PKCE)
+	RedirectURL  string // ruta del frontend que recibe ?code=&state=
+	Scopes       []string
+
+	// Claim con los grupos del usuario (default "groups")
+	GroupsClaim string
+	// Rol asignado a usuarios nuevos cuando ningún grupo calza
+	DefaultRole string
+	// Grupo del IdP => nombre de models.Role
+	GroupRoles map[string]string
+
+	// Vincular sola, en el primer login, una cuenta local con el mismo email (verificado por el IdP).
+	// Solo para IdPs propios: en uno multi-tenant cualquiera puede declarar un email "verificado".
+	// Si es false, el usuario vincula la cuenta desde su sesión.
+	TrustEmailLinking bool
+}
+
+// Provider resuelve el discovery del IdP de forma perezosa (el servidor parte aunque el IdP no responda).
+type Provider struct {
+	Config
+	HTTPClient *http.Client
+
+	mu   sync.Mutex
+	meta *metadata
+	keys *keyCache
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Identity es lo que nos interesa del ID token validado.
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Groups            []string
+}
+
+func NewProvider(cfg Config) *Provider {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{Config: cfg, HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	u := strings.TrimRight(p.IssuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery: status %d", resp.StatusCode)
+	}
+
+	var m metadata
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if strings.TrimRight(m.Issuer, "/") != strings.TrimRight(p.IssuerURL, "/") {
+		return nil, fmt.Errorf("oidc: discovery: issuer %q no coincide con %q", m.Issuer, p.IssuerURL)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery incompleto")
+	}
+
+	p.meta = &m
+	p.keys = &keyCache{url: m.JWKSURI, client: p.HTTPClient}
+	return p.meta, nil
+}
+
+// AuthCodeURL arma la URL de autorización a la que el frontend redirige al usuario.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallengeS256(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return m.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange canjea el code por tokens y devuelve la identidad del ID token ya validado.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oidc: token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		return nil, fmt.Errorf("oidc: token: %s %s", tok.Error, tok.ErrorDescription)
+	}
+	if tok.IDToken == "" {
+		return nil, errors.New("oidc: token: respuesta sin id_token")
+	}
+
+	return p.VerifyIDToken(ctx, tok.IDToken, nonce)
+}
+
+// VerifyIDToken valida firma (JWKS del IdP), iss, aud, exp y nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*Identity, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFn := func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.get(ctx, kid)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, keyFn,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(m.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: id_token inválido: %w", err)
+	}
+
+	gotNonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(gotNonce), []byte(nonce)) != 1 {
+		return nil, errors.New("oidc: nonce inválido")
+	}
+
+	id := &Identity{}
+	id.Subject, _ = claims["sub"].(string)
+	id.Email, _ = claims["email"].(string)
+	id.Name, _ = claims["name"].(string)
+	id.PreferredUsername, _ = claims["preferred_username"].(string)
+
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		id.EmailVerified = v
+	case string: // algunos IdP lo mandan como string
+		id.EmailVerified = v == "true"
+	}
+
+	switch v := claims[p.GroupsClaim].(type) {
+	case []any:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	case string:
+		id.Groups = []string{v}
+	}
+
+	if id.Subject == "" {
+		return nil, errors.New("oidc: id_token sin sub")
+	}
+	return id, nil
+}
+
+// MappedRoles traduce los grupos del IdP a nombres de rol.
+func (p *Provider) MappedRoles(groups []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, g := range groups {
+		if r, ok := p.GroupRoles[g]; ok && !seen[r] {
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// ManagedRoles son los roles que controla el mapeo de grupos (se agregan o quitan en cada login).
+func (p *Provider) ManagedRoles() map[string]bool {
+	out := map[string]bool{}
+	for _, r := range p.GroupRoles {
+		out[r] = true
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+// Package permissions es el catálogo central de permisos. Las rutas solo pueden exigir códigos
+// declarados acá (middleware.RequirePermission falla al registrar la ruta si no) y Sync los
+// sincroniza con la tabla permissions al arrancar.
+package permissions
+
+import (
+	"sort"
+	"strings"
+)
+
+// Permission es un permiso declarado en código. Module es el prefijo del código ("users" en "users:read").
+type Permission struct {
+	Code        string
+	Module      string
+	Description string
+}
+
+// Gestión de usuarios (/api/admin/users)
+const (
+	UsersRead   = "users:read"
+	UsersCreate = "users:create"
+	UsersUpdate = "users:update"
+	UsersDelete = "users:delete"
+	UsersRoles  = "users:roles"
+)
+
+// Bodegas (/api/warehouse)
+const (
+	WarehouseRead   = "warehouse:read"
+	WarehouseCreate = "warehouse:create"
+	WarehouseUpdate = "warehouse:update"
+)
+
+var catalog = []Permission{
+	{Code: UsersRead, Description: "Ver usuarios, exportarlos y descargar sus datos personales"},
+	{Code: UsersCreate, Description: "Crear usuarios (import CSV)"},
+	{Code: UsersUpdate, Description: "Editar usuarios y forzar reset de contraseña"},
+	{Code: UsersDelete, Description: "Borrar y anonimizar usuarios"},
+	{Code: UsersRoles, Description: "Asignar y quitar roles a usuarios"},
+
+	{Code: WarehouseRead, Description: "Ver espacios, pisos y bodegas"},
+	{Code: WarehouseCreate, Description: "Crear espacios, pisos y bodegas"},
+	{Code: WarehouseUpdate, Description: "Configurar bodegas"},
+}
+
+var byCode = func() map[string]Permission {
+	out := map[string]Permission{}
+	for _, p := range All() {
+		out[p.Code] = p
+	}
+	return out
+}()
+
+// All devuelve el catálogo completo ordenado por módulo y código, incluyendo el comodín
+// "<módulo>:*" de cada módulo (lo acepta RequirePermission en lugar de cada permiso del módulo).
+func All() []Permission {
+	out := make([]Permission, 0, len(catalog)+4)
+	modules := map[string]bool{}
+	for _, p := range catalog {
+		p.Module = Module(p.Code)
+		out = append(out, p)
+		modules[p.Module] = true
+	}
+	for m := range modules {
+		out = append(out, Permission{
+			Code:        m + ":*",
+			Module:      m,
+			Description: "Todos los permisos de " + m,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Module != out[j].Module {
+			return out[i].Module < out[j].Module
+		}
+		return out[i].Code < out[j].Code
+	})
+	return out
+}
+
+// Known indica si el código está declarado en el catálogo (incluye comodines de módulo).
+func Known(code string) bool {
+	_, ok := byCode[code]
+	return ok
+}
+
+// Lookup devuelve el permiso declarado con ese código.
+func Lookup(code string) (Permission, bool) {
+	p, ok := byCode[code]
+	return p, ok
+}
+
+// Module devuelve el módulo de un código ("users:read" => "users").
+func Module(code string) string {
+	module, _, _ := strings.Cut(code, ":")
+	return module
+}
@@ -0,0 +1,61 @@
+package permissions
+
+import (
+	"handsoft/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// roleTreeSQL trae los IDs de los roles pedidos (por nombre) y de todos sus ancestros.
+// UNION (no UNION ALL) descarta repetidos, así que un ciclo en la tabla no deja la consulta en loop.
+const roleTreeSQL = `
+WITH RECURSIVE role_tree AS (
+	SELECT id, parent_id FROM roles WHERE name IN ?
+	UNION
+	SELECT r.id, r.parent_id FROM roles r JOIN role_tree t ON r.id = t.parent_id
+)
+SELECT id FROM role_tree`
+
+// EffectiveRoleIDs devuelve los IDs de los roles y de todos sus ancestros (jerarquía por ParentID).
+func EffectiveRoleIDs(db *gorm.DB, roleNames []string) ([]uint, error) {
+	ids := []uint{}
+	if len(roleNames) == 0 {
+		return ids, nil
+	}
+	err := db.Raw(roleTreeSQL, roleNames).Scan(&ids).Error
+	return ids, err
+}
+
+// Effective devuelve los códigos de permiso (ordenados, sin repetir) de los roles, incluidos los heredados.
+func Effective(db *gorm.DB, roleNames []string) ([]string, error) {
+	codes := []string{}
+
+	ids, err := EffectiveRoleIDs(db, roleNames)
+	if err != nil || len(ids) == 0 {
+		return codes, err
+	}
+
+	err = db.Model(&models.Permission{}).
+		Distinct("permissions.code").
+		Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
+		Where("rp.role_id IN ?", ids).
+		Order("permissions.code asc").
+		Pluck("permissions.code", &codes).Error
+	return codes, err
+}
+
+// Granted indica si alguno de los roles (o de sus ancestros) tiene asignado el permiso exacto.
+func Granted(db *gorm.DB, roleNames []string, code string) (bool, error) {
+	ids, err := EffectiveRoleIDs(db, roleNames)
+	if err != nil || len(ids) == 0 {
+		return false, err
+	}
+
+	var count int64
+	err = db.Model(&models.Permission{}).
+		Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
+		Where("rp.role_id IN ?", ids).
+		Where("permissions.code = ?", code).
+		Count(&count).Error
+	return count > 0, err
+}
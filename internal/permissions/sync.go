@@ -0,0 +1,28 @@
+package permissions
+
+import (
+	"handsoft/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sync inserta o actualiza (por código) todos los permisos del catálogo en la tabla permissions.
+// Los permisos que ya no están en el catálogo no se borran (pueden seguir asignados a roles),
+// pero SetRolePermissions ya no permite asignarlos.
+func Sync(db *gorm.DB) error {
+	all := All()
+	rows := make([]models.Permission, 0, len(all))
+	for _, p := range all {
+		rows = append(rows, models.Permission{
+			Code:        p.Code,
+			Module:      p.Module,
+			Description: p.Description,
+		})
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"module", "description", "updated_at"}),
+	}).Create(&rows).Error
+}
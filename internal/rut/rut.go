@@ -0,0 +1,102 @@
+// Package rut valida y normaliza el RUT chileno (Rol Único Tributario) de personas y empresas.
+//
+// Forma canónica (la que se guarda): cuerpo sin puntos ni ceros a la izquierda, guion y dígito
+// verificador en mayúscula. Ej: "12.345.678-5" => "12345678-5", "7.654.321-k" => "7654321-K".
+package rut
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrFormat     = errors.New("rut con formato inválido")
+	ErrCheckDigit = errors.New("dígito verificador del rut inválido")
+)
+
+// Rango razonable del cuerpo (RUTs de empresas llegan a ~99 millones)
+const maxBody = 99_999_999
+
+// CheckDigit calcula el dígito verificador (módulo 11) del cuerpo del RUT.
+func CheckDigit(body int) string {
+	sum, factor := 0, 2
+	for n := body; n > 0; n /= 10 {
+		sum += (n % 10) * factor
+		factor++
+		if factor > 7 {
+			factor = 2
+		}
+	}
+
+	switch dv := 11 - sum%11; dv {
+	case 11:
+		return "0"
+	case 10:
+		return "K"
+	default:
+		return strconv.Itoa(dv)
+	}
+}
+
+// Parse separa cuerpo y dígito verificador, aceptando puntos, espacios y guion opcional.
+func Parse(s string) (int, string, error) {
+	clean := strings.NewReplacer(".", "", " ", "", "-", "").Replace(strings.TrimSpace(s))
+	clean = strings.ToUpper(clean)
+	if len(clean) < 2 {
+		return 0, "", ErrFormat
+	}
+
+	bodyStr, dv := clean[:len(clean)-1], clean[len(clean)-1:]
+	if dv != "K" && (dv < "0" || dv > "9") {
+		return 0, "", ErrFormat
+	}
+	for _, r := range bodyStr {
+		if r < '0' || r > '9' {
+			return 0, "", ErrFormat
+		}
+	}
+
+	body, err := strconv.Atoi(bodyStr)
+	if err != nil || body <= 0 || body > maxBody {
+		return 0, "", ErrFormat
+	}
+
+	if CheckDigit(body) != dv {
+		return 0, "", ErrCheckDigit
+	}
+	return body, dv, nil
+}
+
+// Normalize valida el RUT y lo devuelve en forma canónica ("12345678-5").
+func Normalize(s string) (string, error) {
+	body, dv, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(body) + "-" + dv, nil
+}
+
+// Valid indica si el RUT tiene formato y dígito verificador correctos.
+func Valid(s string) bool {
+	_, _, err := Parse(s)
+	return err == nil
+}
+
+// Format devuelve el RUT con puntos para mostrar ("12.345.678-5").
+func Format(s string) (string, error) {
+	body, dv, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	digits := strconv.Itoa(body)
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + "-" + dv, nil
+}
@@ -0,0 +1,148 @@
+package rut
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestCheckDigit(t *testing.T) {
+	cases := []struct {
+		body int
+		want string
+	}{
+		{12345678, "5"},
+		{11111111, "1"},
+		{24965885, "5"},
+		{5126663, "3"},
+		{10000013, "K"},
+		{16000004, "K"},
+		{6, "K"},
+		{76000000, "0"},
+		{16000009, "0"},
+		{1, "9"},
+		{99999999, "9"},
+	}
+
+	for _, tc := range cases {
+		if got := CheckDigit(tc.body); got != tc.want {
+			t.Errorf("CheckDigit(%d) = %s, se esperaba %s", tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    string
+		wantErr error
+	}{
+		// Válidos en distintos formatos
+		{"12.345.678-5", "12345678-5", nil},
+		{"12345678-5", "12345678-5", nil},
+		{"123456785", "12345678-5", nil},
+		{"  12 345 678 - 5  ", "12345678-5", nil},
+		{"10.000.013-K", "10000013-K", nil},
+		{"10000013-k", "10000013-K", nil},
+		{"10000013k", "10000013-K", nil},
+		{"6-k", "6-K", nil},
+		{"76.000.000-0", "76000000-0", nil},
+		{"0012345678-5", "12345678-5", nil}, // ceros a la izquierda
+		{"99.999.999-9", "99999999-9", nil},
+
+		// Dígito verificador incorrecto
+		{"12.345.678-9", "", ErrCheckDigit},
+		{"12345678-K", "", ErrCheckDigit},
+		{"10000013-0", "", ErrCheckDigit},
+		{"76000000-K", "", ErrCheckDigit},
+
+		// Formato inválido
+		{"", "", ErrFormat},
+		{"   ", "", ErrFormat},
+		{"5", "", ErrFormat},
+		{"-5", "", ErrFormat},
+		{"0-0", "", ErrFormat},
+		{"12.345.678-X", "", ErrFormat},
+		{"12A45678-5", "", ErrFormat},
+		{"K-K", "", ErrFormat},
+		{"100.000.000-4", "", ErrFormat}, // fuera de rango
+		{strings.Repeat("1", 40) + "-1", "", ErrFormat},
+	}
+
+	for _, tc := range cases {
+		got, err := Normalize(tc.in)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("Normalize(%q) error = %v, se esperaba %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Normalize(%q) = %q, se esperaba %q", tc.in, got, tc.want)
+		}
+		if want := tc.wantErr == nil; Valid(tc.in) != want {
+			t.Errorf("Valid(%q) = %v, se esperaba %v", tc.in, !want, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	body, dv, err := Parse("7.654.321-6")
+	if err != nil || body != 7654321 || dv != "6" {
+		t.Fatalf("Parse = %d, %q, %v", body, dv, err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cases := map[string]string{
+		"123456785":  "12.345.678-5",
+		"10000013-k": "10.000.013-K",
+		"5126663-3":  "5.126.663-3",
+		"6-K":        "6-K",
+	}
+	for in, want := range cases {
+		got, err := Format(in)
+		if err != nil || got != want {
+			t.Errorf("Format(%q) = %q, %v; se esperaba %q", in, got, err, want)
+		}
+	}
+
+	if _, err := Format("12.345.678-9"); !errors.Is(err, ErrCheckDigit) {
+		t.Errorf("Format con dígito inválido: err = %v", err)
+	}
+}
+
+func TestBindingValidator(t *testing.T) {
+	RegisterBindingValidator()
+	RegisterBindingValidator() // idempotente
+
+	type req struct {
+		RUT      string  `binding:"omitempty,rut"`
+		Required string  `binding:"required,rut"`
+		Pointer  *string `binding:"omitempty,rut"`
+	}
+	ptr := func(s string) *string { return &s }
+
+	cases := []struct {
+		name string
+		in   req
+		ok   bool
+	}{
+		{"válidos", req{RUT: "12.345.678-5", Required: "10000013-k", Pointer: ptr("6-K")}, true},
+		{"opcionales vacíos", req{Required: "123456785"}, true},
+		{"requerido vacío", req{}, false},
+		{"dígito incorrecto", req{Required: "12.345.678-9"}, false},
+		{"opcional inválido", req{RUT: "abc", Required: "12345678-5"}, false},
+		{"puntero inválido", req{Required: "12345678-5", Pointer: ptr("12345678-K")}, false},
+		{"demasiado largo", req{Required: strings.Repeat("9", 33)}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := binding.Validator.ValidateStruct(tc.in)
+			if (err == nil) != tc.ok {
+				t.Errorf("ValidateStruct = %v, se esperaba ok=%v", err, tc.ok)
+			}
+		})
+	}
+}
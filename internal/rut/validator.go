@@ -0,0 +1,24 @@
+package rut
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var registerOnce sync.Once
+
+// RegisterBindingValidator agrega la regla `binding:"rut"` al validador de gin.
+// Acepta strings y *string; usar con omitempty si el campo es opcional.
+func RegisterBindingValidator() {
+	registerOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		_ = v.RegisterValidation("rut", func(fl validator.FieldLevel) bool {
+			return Valid(fl.Field().String())
+		})
+	})
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Store guarda los archivos en un bucket S3 o compatible (MinIO, R2, ...), firmando con SigV4.
+//
+// Endpoint es la URL base del servicio ("https://s3.us-east-1.amazonaws.com", "http://localhost:9000").
+// Con PathStyle el bucket va en el path (MinIO); si no, como subdominio (AWS).
+// PublicURL es la base de las URLs públicas (ej: un CDN); vacío = URL del objeto en el endpoint.
+type S3Store struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	PublicURL string
+
+	// Opcional (default: cliente con timeout de 30s)
+	Client *http.Client
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	headers := http.Header{}
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	return s.do(ctx, http.MethodPut, key, data, headers)
+}
+
+// Delete borra el objeto (S3 responde 204 aunque no exista).
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	return s.do(ctx, http.MethodDelete, key, nil, http.Header{})
+}
+
+func (s *S3Store) URL(key string) string {
+	if s.PublicURL != "" {
+		return strings.TrimRight(s.PublicURL, "/") + "/" + key
+	}
+	u, err := s.objectURL(key)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+	base, err := url.Parse(strings.TrimRight(s.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("endpoint S3 inválido: %q", s.Endpoint)
+	}
+
+	u := *base
+	if s.PathStyle {
+		u.Path = base.Path + "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + base.Host
+		u.Path = base.Path + "/" + key
+	}
+	return &u, nil
+}
+
+func (s *S3Store) do(ctx context.Context, method, key string, body []byte, headers http.Header) error {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = sha256Hex(body)
+	}
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	signV4(req, payloadHash, s.AccessKey, s.SecretKey, region, "s3", time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Store guarda archivos por key ("avatars/12/abc-64.jpg"). Implementaciones: LocalStore (disco)
+// y S3Store (S3 o compatible, ej: MinIO). Los archivos son chicos, se pasan completos en memoria.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL pública del archivo
+	URL(key string) string
+}
+
+var ErrInvalidKey = errors.New("key de archivo inválida")
+
+// validKey rechaza keys vacías, absolutas o con ".." (no deben salir del directorio/bucket).
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}